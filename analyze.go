@@ -78,10 +78,10 @@ type tagDeltaData struct {
 
 func analyzeData() {
 	loadConfiguration()
-	database := newDatabaseClient()
+	database := newStorage()
 	defer database.close()
 	closed := true
-	historyData := database.getPriceHistoryData(&closed, nil, nil, nil)
+	historyData := database.getPriceHistoryData(getHistoryFidelity(), &closed, nil, nil, nil)
 	// analyzeCategories(false, historyData)
 	// analyzeCategories(true, historyData)
 	// analyzeMonthlyDistribution(false, 0, 1, 0.4, 0.6, historyData)
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log"
+	"math/rand/v2"
+	"time"
+)
+
+const (
+	backoffInitialDelay = 1
+	backoffMaxDelay = 300
+	backoffResetTime = 60
+)
+
+type reconnectBackoff struct {
+	attempt int
+	connected time.Time
+}
+
+func newReconnectBackoff() *reconnectBackoff {
+	return &reconnectBackoff{
+		attempt: 0,
+		connected: time.Now(),
+	}
+}
+
+func (b *reconnectBackoff) connect() {
+	b.connected = time.Now()
+}
+
+func (b *reconnectBackoff) wait() {
+	if time.Since(b.connected) >= time.Duration(backoffResetTime) * time.Second {
+		b.attempt = 0
+	}
+	delay := getBackoffDelay(b.attempt)
+	b.attempt++
+	if b.attempt > 1 {
+		log.Printf("Reconnecting in %.1f s (attempt %d)", delay.Seconds(), b.attempt)
+	}
+	time.Sleep(delay)
+}
+
+func getBackoffDelay(attempt int) time.Duration {
+	delay := time.Duration(backoffInitialDelay) * time.Second << min(attempt, 16)
+	delay = min(delay, time.Duration(backoffMaxDelay) * time.Second)
+	return delay / 2 + rand.N(delay / 2 + 1)
+}
\ No newline at end of file
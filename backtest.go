@@ -47,12 +47,23 @@ type backtestDailyData struct {
 	historyData map[string]*PriceHistoryBSON
 }
 
+type backtestDataSet struct {
+	historyMap map[string]*PriceHistoryBSON
+	dailyData map[time.Time]backtestDailyData
+	prices map[backtestPriceKey]float64
+	noPrices map[backtestPriceKey]float64
+	marketEvents map[string]*EventBSON
+	eventMarkets map[string][]string
+	ticks []backtestTick
+}
+
 type backtestPriceKey struct {
 	slug string
 	timestamp time.Time
 }
 
 type backtestData struct {
+	fill fillModel
 	cash float64
 	maxCash float64
 	maxDrawdown float64
@@ -63,6 +74,10 @@ type backtestData struct {
 	historyMap map[string]*PriceHistoryBSON
 	dailyData map[time.Time]backtestDailyData
 	prices map[backtestPriceKey]float64
+	noPrices map[backtestPriceKey]float64
+	marketEvents map[string]*EventBSON
+	eventMarkets map[string][]string
+	tickPrices map[string]backtestTickPrice
 	tagPerformance map[string]performanceData[string]
 	hourPerformance map[int]performanceData[int]
 	weekdayPerformance map[int]performanceData[int]
@@ -70,6 +85,11 @@ type backtestData struct {
 	recentTrades deque.Deque[backtestTrade]
 }
 
+type backtestTickPrice struct {
+	price float64
+	timestamp time.Time
+}
+
 type backtestPosition struct {
 	slug string
 	timestamp time.Time
@@ -106,24 +126,41 @@ type performanceData[K any] struct {
 	returns []float64
 }
 
+type backtestPrintOptions struct {
+	tags bool
+	hours bool
+	weekdays bool
+	prices bool
+	recentTrades bool
+}
+
 type backtestTrade struct {
 	timestamp time.Time
 	slug string
 	profit float64
 }
 
-func loadBacktestData() (map[string]*PriceHistoryBSON, map[time.Time]backtestDailyData, map[backtestPriceKey]float64) {
-	database := newDatabaseClient()
+func loadBacktestData() *backtestDataSet {
+	database := newStorage()
 	defer database.close()
+	return getBacktestDataSet(database)
+}
+
+func getBacktestDataSet(database storage) *backtestDataSet {
 	negRisk := backtestNegRisk
 	minVolume := backtestMinVolume
-	historyData := database.getPriceHistoryData(nil, &negRisk, &minVolume, nil)
+	historyData := database.getPriceHistoryData(getHistoryFidelity(), nil, &negRisk, &minVolume, nil)
 	historyMap := map[string]*PriceHistoryBSON{}
 	dailyData := map[time.Time]backtestDailyData{}
 	prices := map[backtestPriceKey]float64{}
+	noPrices := map[backtestPriceKey]float64{}
+	eventMarkets := map[string][]string{}
 	for i := range historyData {
 		history := &historyData[i]
 		historyMap[history.Slug] = history
+		if history.EventSlug != "" {
+			eventMarkets[history.EventSlug] = append(eventMarkets[history.EventSlug], history.Slug)
+		}
 		for _, price := range history.History {
 			date := commons.GetDate(price.Timestamp)
 			data, exists := dailyData[date]
@@ -141,28 +178,68 @@ func loadBacktestData() (map[string]*PriceHistoryBSON, map[time.Time]backtestDai
 			}
 			prices[priceKey] = price.Price
 		}
+		for _, price := range history.NoHistory {
+			priceKey := backtestPriceKey{
+				slug: history.Slug,
+				timestamp: commons.GetHourTimestamp(price.Timestamp),
+			}
+			noPrices[priceKey] = price.Price
+		}
+	}
+	events := database.getEvents()
+	marketEvents := map[string]*EventBSON{}
+	for i := range events {
+		event := &events[i]
+		for _, slug := range event.Markets {
+			marketEvents[slug] = event
+		}
 	}
-	return historyMap, dailyData, prices
+	dataSet := backtestDataSet{
+		historyMap: historyMap,
+		dailyData: dailyData,
+		prices: prices,
+		noPrices: noPrices,
+		marketEvents: marketEvents,
+		eventMarkets: eventMarkets,
+		ticks: nil,
+	}
+	return &dataSet
 }
 
 func executeBacktest(
 	strategy backtestStrategy,
 	start time.Time,
 	end time.Time,
-	historyMap map[string]*PriceHistoryBSON,
-	dailyData map[time.Time]backtestDailyData,
-	prices map[backtestPriceKey]float64,
+	initialCash float64,
+	fill fillModel,
+	data *backtestDataSet,
 ) backtestResult {
+	backtest := newBacktestData(start, initialCash, fill, data)
+	for backtest.now.Before(end) {
+		strategy.next(&backtest)
+		backtest.resolveMarkets()
+		backtest.updateStats()
+		backtest.now = backtest.now.Add(time.Hour)
+	}
+	return getBacktestResult(&backtest, start, end, initialCash)
+}
+
+func newBacktestData(start time.Time, initialCash float64, fill fillModel, data *backtestDataSet) backtestData {
 	backtest := backtestData{
-		cash: backtestInitialCash,
-		maxCash: backtestInitialCash,
+		fill: fill,
+		cash: initialCash,
+		maxCash: initialCash,
 		maxDrawdown: 0.0,
 		positions: []backtestPosition{},
 		now: start,
 		trades: 0,
-		historyMap: historyMap,
-		dailyData: dailyData,
-		prices: prices,
+		historyMap: data.historyMap,
+		dailyData: data.dailyData,
+		prices: data.prices,
+		noPrices: data.noPrices,
+		marketEvents: data.marketEvents,
+		eventMarkets: data.eventMarkets,
+		tickPrices: nil,
 		tagPerformance: map[string]performanceData[string]{},
 		hourPerformance: map[int]performanceData[int]{},
 		weekdayPerformance: map[int]performanceData[int]{},
@@ -171,21 +248,19 @@ func executeBacktest(
 	}
 	sample := EquityCurveSample{
 		Timestamp: commons.GetDate(start),
-		Cash: backtestInitialCash,
+		Cash: initialCash,
 	}
 	backtest.equityCurve = []EquityCurveSample{
 		sample,
 	}
-	for backtest.now.Before(end) {
-		strategy.next(&backtest)
-		backtest.resolveMarkets()
-		backtest.updateStats()
-		backtest.now = backtest.now.Add(time.Hour)
-	}
+	return backtest
+}
+
+func getBacktestResult(backtest *backtestData, start time.Time, end time.Time, initialCash float64) backtestResult {
 	backtest.closeAllPositions()
 	backtest.addEquityCurveSample(end, backtest.cash)
-	totalReturn := getRateOfChange(backtest.cash, backtestInitialCash)
-	sharpeRatio := backtest.getSharpeRatio()
+	totalReturn := getRateOfChange(backtest.cash, initialCash)
+	sharpeRatio := getSharpeRatio(backtest.equityCurve)
 	tagPerformance := sortMapByValue(backtest.tagPerformance, func (a, b performanceData[string]) int {
 		return cmp.Compare(b.trades, a.trades)
 	})
@@ -239,6 +314,31 @@ func (b *backtestData) getMarkets(tags []string) []*PriceHistoryBSON {
 }
 
 func (b *backtestData) getPriceErr(slug string) (float64, bool) {
+	price, exists := b.getTickPrice(slug)
+	if exists {
+		return price, true
+	}
+	return b.getHourlyPrice(b.prices, slug)
+}
+
+func (b *backtestData) getTickPrice(slug string) (float64, bool) {
+	tick, exists := b.tickPrices[slug]
+	if !exists || b.now.Sub(tick.timestamp) >= time.Duration(backtestMaxPriceOffset) * time.Hour {
+		return 0.0, false
+	}
+	_, timestamp, exists := b.getHourlySample(b.prices, slug)
+	if exists && tick.timestamp.Before(timestamp) {
+		return 0.0, false
+	}
+	return tick.price, true
+}
+
+func (b *backtestData) getHourlyPrice(prices map[backtestPriceKey]float64, slug string) (float64, bool) {
+	price, _, exists := b.getHourlySample(prices, slug)
+	return price, exists
+}
+
+func (b *backtestData) getHourlySample(prices map[backtestPriceKey]float64, slug string) (float64, time.Time, bool) {
 	for i := range backtestMaxPriceOffset {
 		duration := time.Duration(- i) * time.Hour
 		timestamp := b.now.Add(duration)
@@ -246,13 +346,58 @@ func (b *backtestData) getPriceErr(slug string) (float64, bool) {
 			slug: slug,
 			timestamp: timestamp,
 		}
-		price, exists := b.prices[priceKey]
+		price, exists := prices[priceKey]
 		if !exists {
 			continue
 		}
-		return price, true
+		return price, timestamp, true
 	}
-	return 0.0, false
+	return 0.0, time.Time{}, false
+}
+
+func (b *backtestData) getSiblingMarkets(slug string, timestamp time.Time) []*PriceHistoryBSON {
+	slugs := []string{}
+	event, exists := b.marketEvents[slug]
+	if exists {
+		slugs = append(slugs, event.Markets...)
+	}
+	history, exists := b.historyMap[slug]
+	if exists {
+		for _, sibling := range b.eventMarkets[history.EventSlug] {
+			if !commons.Contains(slugs, sibling) {
+				slugs = append(slugs, sibling)
+			}
+		}
+	}
+	siblings := []*PriceHistoryBSON{}
+	for _, sibling := range slugs {
+		if sibling == slug {
+			continue
+		}
+		history, exists := b.historyMap[sibling]
+		if !exists || len(history.History) == 0 {
+			continue
+		}
+		first := history.History[0].Timestamp
+		last := history.History[len(history.History) - 1].Timestamp
+		if first.After(timestamp) || last.Before(timestamp) {
+			continue
+		}
+		siblings = append(siblings, history)
+	}
+	return siblings
+}
+
+func (b *backtestData) getBidAsk(slug string, side backtestPositionSide) (float64, float64) {
+	price := b.getPrice(slug)
+	_, tick := b.getTickPrice(slug)
+	if side == sideNo && !tick {
+		noPrice, exists := b.getHourlyPrice(b.noPrices, slug)
+		if exists {
+			return getBidAsk(noPrice, sideYes)
+		}
+	}
+	return getBidAsk(price, side)
 }
 
 func (b *backtestData) getPrice(slug string) float64 {
@@ -274,21 +419,23 @@ func (b *backtestData) getPrice(slug string) float64 {
 }
 
 func (b *backtestData) openPosition(slug string, side backtestPositionSide, size float64) bool {
-	price, exists := b.getPriceErr(slug)
+	ask, filled, exists := b.fill.getFill(b, slug, side, size, true)
 	if !exists {
 		return false
 	}
-	_, ask := getBidAsk(price, side)
-	cost := size * ask
+	cost := filled * ask
 	if cost > b.cash {
 		return false
 	}
+	if backtestDebugPositions && filled < size {
+		fmt.Printf("%s Partial fill on %s: %.2f out of %.2f\n", commons.GetTimeString(b.now), slug, filled, size)
+	}
 	position := backtestPosition{
 		slug: slug,
 		timestamp: b.now,
 		side: side,
 		price: ask,
-		size: size,
+		size: filled,
 	}
 	b.positions = append(b.positions, position)
 	b.cash -= cost
@@ -310,8 +457,7 @@ func (b *backtestData) closePositions(slug string) bool {
 	newPositions := []backtestPosition{}
 	for _, position := range b.positions {
 		if position.slug == slug {
-			price := b.getPrice(slug)
-			bid, _ := getBidAsk(price, position.side)
+			bid := b.getExitPrice(position)
 			b.cash += position.size * bid
 			profit := position.size * (bid - position.price)
 			if backtestDebugPositions {
@@ -339,6 +485,20 @@ func (b *backtestData) closePositions(slug string) bool {
 	return hit
 }
 
+func (b *backtestData) getExitPrice(position backtestPosition) float64 {
+	price, filled, exists := b.fill.getFill(b, position.slug, position.side, position.size, false)
+	if !exists {
+		price = 0.0
+		filled = 0.0
+	}
+	if filled < position.size {
+		remainder := position.size - filled
+		bid, _ := b.getBidAsk(position.slug, position.side)
+		price = (filled * price + remainder * bid) / position.size
+	}
+	return price
+}
+
 func (b *backtestData) updatePerformanceStats(slug string, profit float64, position backtestPosition) {
 	b.trades++
 	history, exists := b.historyMap[slug]
@@ -359,8 +519,7 @@ func (b *backtestData) updatePerformanceStats(slug string, profit float64, posit
 func (b *backtestData) getNetWorth() float64 {
 	netWorth := b.cash
 	for _, position := range b.positions {
-		price := b.getPrice(position.slug)
-		bid, _ := getBidAsk(price, position.side)
+		bid, _ := b.getBidAsk(position.slug, position.side)
 		netWorth += position.size * bid
 	}
 	return netWorth
@@ -407,10 +566,10 @@ func (b *backtestData) updateStats() {
 	b.addEquityCurveSample(b.now, netWorth)
 }
 
-func (b *backtestData) getSharpeRatio() float64 {
+func getSharpeRatio(equityCurve []EquityCurveSample) float64 {
 	returns := []float64{}
-	previousSample := b.equityCurve[0]
-	for _, sample := range b.equityCurve[1:] {
+	previousSample := equityCurve[0]
+	for _, sample := range equityCurve[1:] {
 		if sample.Timestamp.Month() != previousSample.Timestamp.Month() {
 			monthlyReturns := getRateOfChange(sample.Cash, previousSample.Cash)
 			returns = append(returns, monthlyReturns)
@@ -438,7 +597,7 @@ func (b *backtestData) addEquityCurveSample(date time.Time, cash float64) {
 	b.equityCurve = append(b.equityCurve, sample)
 }
 
-func (r *backtestResult) print() {
+func (r *backtestResult) print(options backtestPrintOptions) {
 	fmt.Printf("\tStart: %s\n", commons.GetDateString(r.start))
 	fmt.Printf("\tEnd: %s\n", commons.GetDateString(r.end))
 	fmt.Printf("\tCash: %s\n", commons.FormatMoney(r.cash))
@@ -446,7 +605,7 @@ func (r *backtestResult) print() {
 	fmt.Printf("\tMax drawdown: %.2f%%\n", percent * r.maxDrawdown)
 	fmt.Printf("\tSharpe ratio: %.2f\n", r.sharpeRatio)
 	fmt.Printf("\tTrades: %d\n", r.trades)
-	if backtestPrintTags {
+	if options.tags {
 		fmt.Printf("\n\tProfit by tag:\n")
 		for i, performance := range r.tagPerformance {
 			if i >= 25 {
@@ -455,7 +614,7 @@ func (r *backtestResult) print() {
 			fmt.Printf("\t\t%d. %s: %s (%d trades)\n", i + 1, performance.key, commons.FormatMoney(performance.profit), performance.trades)
 		}
 	}
-	if backtestPrintHours {
+	if options.hours {
 		fmt.Printf("\n\tProfit by hour:\n")
 		for _, performance := range r.hourPerformance {
 			hour1 := 4 * performance.key
@@ -465,7 +624,7 @@ func (r *backtestResult) print() {
 			fmt.Printf(format, hour1, hour2, riskAdjusted, profit, performance.trades)
 		}
 	}
-	if backtestPrintWeekdays {
+	if options.weekdays {
 		fmt.Printf("\n\tProfit by weekday:\n")
 		for _, performance := range r.weekdayPerformance {
 			weekday := time.Weekday(performance.key)
@@ -474,7 +633,7 @@ func (r *backtestResult) print() {
 			fmt.Printf(format, weekday, riskAdjusted, profit, performance.trades)
 		}
 	}
-	if backtestPrintPrice {
+	if options.prices {
 		fmt.Printf("\n\tProfit by initial price:\n")
 		for _, performance := range r.pricePerformance {
 			price1 := float64(performance.key) / 10.0
@@ -483,7 +642,7 @@ func (r *backtestResult) print() {
 			fmt.Printf("\t\t%.1f - %.1f: %.2f RAR, $%.2f/trade, %d trades\n", price1, price2, riskAdjusted, profit, performance.trades)
 		}
 	}
-	if backtestPrintRecentTrades {
+	if options.recentTrades {
 		fmt.Printf("\n\tRecent trades:\n")
 		for trade := range r.recentTrades.Iter() {
 			fmt.Printf("\t\t%s %s: %s\n", commons.GetTimeString(trade.timestamp), trade.slug, commons.FormatMoney(trade.profit))
@@ -491,6 +650,16 @@ func (r *backtestResult) print() {
 	}
 }
 
+func getDefaultPrintOptions() backtestPrintOptions {
+	return backtestPrintOptions{
+		tags: backtestPrintTags,
+		hours: backtestPrintHours,
+		weekdays: backtestPrintWeekdays,
+		prices: backtestPrintPrice,
+		recentTrades: backtestPrintRecentTrades,
+	}
+}
+
 func (p* performanceData[K]) getStats() (float64, float64) {
 	profit := p.profit / float64(p.trades)
 	riskAdjusted := stat.Mean(p.returns, nil) / stat.StdDev(p.returns, nil)
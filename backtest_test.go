@@ -0,0 +1,86 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+const (
+	siblingTestEvent = "sibling-test-event"
+	siblingTestOtherEvent = "sibling-test-other-event"
+)
+
+func TestBacktestSiblingMarkets(t *testing.T) {
+	loadReplayTestConfiguration(t)
+	database := newFileStorage(t.TempDir())
+	histories := []struct {
+		slug string
+		eventSlug string
+		offset int
+	}{
+		{"sibling-test-a", siblingTestEvent, 0},
+		{"sibling-test-b", siblingTestEvent, 0},
+		{"sibling-test-c", siblingTestEvent, 24},
+		{"sibling-test-d", siblingTestEvent, 0},
+		{"sibling-test-other", siblingTestOtherEvent, 0},
+	}
+	for _, history := range histories {
+		database.insertPriceHistory(getSiblingTestHistory(history.slug, history.eventSlug, history.offset))
+	}
+	event := EventBSON{
+		EventID: "1",
+		Slug: siblingTestEvent,
+		Title: "Sibling test event",
+		NegRisk: false,
+		Closed: false,
+		Tags: []string{},
+		Markets: []string{"sibling-test-a", "sibling-test-b", "sibling-test-c"},
+		Updated: replayTestStart,
+	}
+	database.upsertEvent(event)
+	data := getBacktestDataSet(database)
+	backtest := newBacktestData(replayTestStart, backtestInitialCash, nil, data)
+	expectSiblings := func (slug string, hours int, expected []string) {
+		siblings := []string{}
+		for _, history := range backtest.getSiblingMarkets(slug, replayTestStart.Add(time.Duration(hours) * time.Hour)) {
+			siblings = append(siblings, history.Slug)
+		}
+		slices.Sort(siblings)
+		if !slices.Equal(siblings, expected) {
+			t.Errorf("expected siblings of %s after %d hours to be %v, got %v", slug, hours, expected, siblings)
+		}
+	}
+	expectSiblings("sibling-test-a", 1, []string{"sibling-test-b", "sibling-test-d"})
+	expectSiblings("sibling-test-a", 30, []string{"sibling-test-b", "sibling-test-c", "sibling-test-d"})
+	expectSiblings("sibling-test-d", 1, []string{"sibling-test-a", "sibling-test-b"})
+	expectSiblings("sibling-test-other", 1, []string{})
+	expectSiblings("sibling-test-a", 100, []string{})
+}
+
+func getSiblingTestHistory(slug string, eventSlug string, offset int) PriceHistoryBSON {
+	samples := []PriceHistorySampleBSON{}
+	for i := offset; i <= 48; i++ {
+		sample := PriceHistorySampleBSON{
+			Timestamp: replayTestStart.Add(time.Duration(i) * time.Hour),
+			Price: 0.5,
+		}
+		samples = append(samples, sample)
+	}
+	return PriceHistoryBSON{
+		Slug: slug,
+		EventID: "",
+		EventSlug: eventSlug,
+		NegRisk: false,
+		Closed: false,
+		Fidelity: getHistoryFidelity(),
+		StartDate: replayTestStart,
+		EndDate: nil,
+		Volume: backtestMinVolume,
+		Outcome: nil,
+		Tags: []string{},
+		History: samples,
+		NoHistory: []PriceHistorySampleBSON{},
+		NoFetched: true,
+	}
+}
\ No newline at end of file
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/encratite/gamma"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	resyncInterval = 30
+)
+
+type bookIntegrity struct {
+	stale bool
+	divergences int
+	crossed int
+	lastResync time.Time
+}
+
+type BookSnapshot struct {
+	Market string `json:"market"`
+	AssetID string `json:"asset_id"`
+	Timestamp string `json:"timestamp"`
+	Hash string `json:"hash"`
+	Bids []BookLevel `json:"bids"`
+	Asks []BookLevel `json:"asks"`
+}
+
+type BookLevel struct {
+	Price string `json:"price"`
+	Size string `json:"size"`
+}
+
+func (b *bookIntegrity) checkPriceChange(slug string, bids, asks *treemap.Map, change gamma.PriceChange) {
+	expectedBid, err := decimal.NewFromString(change.BestBid)
+	if err != nil {
+		return
+	}
+	expectedAsk, err := decimal.NewFromString(change.BestAsk)
+	if err != nil {
+		return
+	}
+	bestBid, bestAsk := getTopOfBook(bids, asks)
+	if bestBid.Equal(expectedBid) && bestAsk.Equal(expectedAsk) {
+		return
+	}
+	b.divergences++
+	reason := fmt.Sprintf("local top of book %s/%s differs from %s/%s", bestBid, bestAsk, expectedBid, expectedAsk)
+	b.markStale(slug, reason)
+}
+
+func (b *bookIntegrity) checkCrossed(slug string, bids, asks *treemap.Map) {
+	if bids.Size() == 0 || asks.Size() == 0 {
+		return
+	}
+	bestBid, bestAsk := getTopOfBook(bids, asks)
+	if bestAsk.GreaterThan(bestBid) {
+		return
+	}
+	b.crossed++
+	reason := fmt.Sprintf("crossed book with best bid %s and best ask %s", bestBid, bestAsk)
+	b.markStale(slug, reason)
+}
+
+func (b *bookIntegrity) markStale(slug, reason string) {
+	if !b.stale {
+		log.Printf("Warning: order book of %s is stale: %s", slug, reason)
+	}
+	b.stale = true
+}
+
+func (b *bookIntegrity) onSnapshot(slug string) {
+	if b.stale {
+		log.Printf("Order book of %s has been resynchronized", slug)
+	}
+	b.stale = false
+}
+
+func shouldResync(integrity *bookIntegrity, slug string) bool {
+	now := time.Now()
+	if now.Sub(integrity.lastResync) < time.Duration(resyncInterval) * time.Second {
+		return false
+	}
+	integrity.lastResync = now
+	log.Printf("Requesting a fresh order book snapshot of %s", slug)
+	return true
+}
+
+func requestBookSnapshot(conditionID, assetID string, loop *eventLoop, onSnapshot func (message gamma.BookMessage)) {
+	go func () {
+		message, err := getBookSnapshot(conditionID, assetID)
+		if err != nil {
+			log.Printf("Failed to request order book snapshot of %s: %v", assetID, err)
+			return
+		}
+		loop.post(func () {
+			onSnapshot(message)
+		})
+	}()
+}
+
+func getBookSnapshot(conditionID, assetID string) (gamma.BookMessage, error) {
+	response, err := http.Get(clobURL + "/book?token_id=" + assetID)
+	if err != nil {
+		return gamma.BookMessage{}, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return gamma.BookMessage{}, fmt.Errorf("order book request failed with status code %d", response.StatusCode)
+	}
+	var snapshot BookSnapshot
+	err = json.NewDecoder(response.Body).Decode(&snapshot)
+	if err != nil {
+		return gamma.BookMessage{}, err
+	}
+	message := gamma.BookMessage{
+		EventType: gamma.BookEvent,
+		AssetID: assetID,
+		Market: conditionID,
+		Timestamp: snapshot.Timestamp,
+		Hash: snapshot.Hash,
+		Bids: getSnapshotLevels(snapshot.Bids),
+		Asks: getSnapshotLevels(snapshot.Asks),
+	}
+	return message, nil
+}
+
+func getSnapshotLevels(levels []BookLevel) []gamma.OrderSummary {
+	summaries := []gamma.OrderSummary{}
+	for _, level := range levels {
+		summary := gamma.OrderSummary{
+			Price: level.Price,
+			Size: level.Size,
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func getTopOfBook(bids, asks *treemap.Map) (decimal.Decimal, decimal.Decimal) {
+	bestBid := decimal.Zero
+	bestAsk := decimal.NewFromInt(1)
+	bidKey, _ := bids.Max()
+	if bidKey != nil {
+		bestBid = bidKey.(decimal.Decimal)
+	}
+	askKey, _ := asks.Min()
+	if askKey != nil {
+		bestAsk = askKey.(decimal.Decimal)
+	}
+	return bestBid, bestAsk
+}
\ No newline at end of file
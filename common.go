@@ -9,6 +9,8 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+var simulatedTime *time.Time
+
 type keyValuePair[K comparable, V any] struct {
 	key K
 	value V
@@ -66,4 +68,11 @@ func getRateOfChange(newValue, oldValue float64) float64 {
 
 func mustParseTime(timeString string) time.Time {
 	return commons.MustParseTime(timeString)
+}
+
+func getTime() time.Time {
+	if simulatedTime != nil {
+		return *simulatedTime
+	}
+	return time.Now()
 }
\ No newline at end of file
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"sync/atomic"
+	"time"
 
 	"github.com/shopspring/decimal"
 	"gopkg.in/yaml.v3"
@@ -20,6 +23,11 @@ type Configuration struct {
 	Database DatabaseConfiguration `yaml:"database"`
 	Trigger TriggerModeConfiguration `yaml:"trigger"`
 	Jump JumpConfiguration `yaml:"jump"`
+	Paper PaperConfiguration `yaml:"paper"`
+	Notifications NotificationConfiguration `yaml:"notifications"`
+	Risk RiskConfiguration `yaml:"risk"`
+	Download DownloadConfiguration `yaml:"download"`
+	History HistoryConfiguration `yaml:"history"`
 	Earnings []EarningsConfiguration `yaml:"earnings"`
 }
 
@@ -37,11 +45,14 @@ type DataModeConfiguration struct {
 	Events []string `yaml:"events"`
 	MinVolume *SerializableDecimal `yaml:"minVolume"`
 	BufferTimeSpan *int `yaml:"bufferTimeSpan"`
+	WAL *string `yaml:"wal"`
 }
 
 type TriggerModeConfiguration struct {
 	Live *bool `yaml:"live"`
 	RecordData *bool `yaml:"recordData"`
+	Paper *bool `yaml:"paper"`
+	State *string `yaml:"state"`
 	Triggers []Trigger `yaml:"triggers"`
 }
 
@@ -51,6 +62,11 @@ type Trigger struct {
 	TakeProfitLimit *SerializableDecimal `yaml:"takeProfitLimit"`
 	StopLoss *SerializableDecimal `yaml:"stopLoss"`
 	StopLossLimit *SerializableDecimal `yaml:"stopLossLimit"`
+	TrailingStop *SerializableDecimal `yaml:"trailingStop"`
+	ExitTime *time.Time `yaml:"exitTime"`
+	ExitBeforeEnd *int `yaml:"exitBeforeEnd"`
+	ExitSlippage *SerializableDecimal `yaml:"exitSlippage"`
+	Bracket *bool `yaml:"bracket"`
 }
 
 type JumpConfiguration struct {
@@ -60,6 +76,50 @@ type JumpConfiguration struct {
 	SpreadLimit *SerializableDecimal `yaml:"spreadLimit"`
 	IncludeTags []string `yaml:"includeTags"`
 	ExcludeTags []string `yaml:"excludeTags"`
+	AutoTrade *bool `yaml:"autoTrade"`
+	Live *bool `yaml:"live"`
+	Paper *bool `yaml:"paper"`
+	Size *SerializableDecimal `yaml:"size"`
+	HoldingTime *int `yaml:"holdingTime"`
+	EntryTimeout *int `yaml:"entryTimeout"`
+	StopLoss *bool `yaml:"stopLoss"`
+	State *string `yaml:"state"`
+}
+
+type PaperConfiguration struct {
+	Ledger *string `yaml:"ledger"`
+	InitialCash *SerializableDecimal `yaml:"initialCash"`
+}
+
+type NotificationConfiguration struct {
+	Beep *bool `yaml:"beep"`
+	Desktop *bool `yaml:"desktop"`
+	AlertLog *string `yaml:"alertLog"`
+	Webhook *WebhookConfiguration `yaml:"webhook"`
+	Email *EmailConfiguration `yaml:"email"`
+}
+
+type WebhookConfiguration struct {
+	URL *string `yaml:"url"`
+	Timeout *int `yaml:"timeout"`
+}
+
+type EmailConfiguration struct {
+	Host *string `yaml:"host"`
+	Port *int `yaml:"port"`
+	Username *string `yaml:"username"`
+	Password *string `yaml:"password"`
+	From *string `yaml:"from"`
+	To []string `yaml:"to"`
+}
+
+type RiskConfiguration struct {
+	MaxOrderNotional *SerializableDecimal `yaml:"maxOrderNotional"`
+	MaxMarketPosition *SerializableDecimal `yaml:"maxMarketPosition"`
+	MaxTagExposure *SerializableDecimal `yaml:"maxTagExposure"`
+	MaxDailyLoss *SerializableDecimal `yaml:"maxDailyLoss"`
+	Breach *string `yaml:"breach"`
+	KillSwitch *string `yaml:"killSwitch"`
 }
 
 type EarningsConfiguration struct {
@@ -68,8 +128,26 @@ type EarningsConfiguration struct {
 }
 
 type DatabaseConfiguration struct {
+	Backend *string `yaml:"backend"`
 	URI *string `yaml:"uri"`
 	Database *string `yaml:"database"`
+	Path *string `yaml:"path"`
+}
+
+type DownloadConfiguration struct {
+	Workers *int `yaml:"workers"`
+	RequestsPerSecond *float64 `yaml:"requestsPerSecond"`
+	Retries *int `yaml:"retries"`
+	Cursor *string `yaml:"cursor"`
+}
+
+type HistoryConfiguration struct {
+	Start *commons.SerializableDate `yaml:"start"`
+	End *commons.SerializableDate `yaml:"end"`
+	Order *string `yaml:"order"`
+	MaxMarkets *int `yaml:"maxMarkets"`
+	Fidelities []int `yaml:"fidelities"`
+	Tags []string `yaml:"tags"`
 }
 
 type ProfitConfiguration struct {
@@ -103,47 +181,93 @@ type SerializableDecimal struct {
 	decimal.Decimal
 }
 
-var configuration *Configuration
+var configuration atomic.Pointer[Configuration]
 var profitConfiguration *ProfitConfiguration
 
 func loadConfiguration() {
-	configuration = commons.LoadConfiguration(configurationPath, configuration)
-	configuration.validate()
+	var config *Configuration
+	config = commons.LoadConfiguration(configurationPath, config)
+	err := config.validate()
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	setConfiguration(config)
+	initializeNotifiers()
 	profitConfiguration = commons.LoadConfiguration(profitConfigurationPath, profitConfiguration)
 }
 
-func (c *Configuration) validate() {
-	c.Data.validate()
-	c.Database.validate()
-	c.Trigger.validate()
+func getConfiguration() *Configuration {
+	return configuration.Load()
+}
+
+func setConfiguration(config *Configuration) {
+	configuration.Store(config)
+}
+
+func validateJumpConfiguration() {
+	err := getConfiguration().Jump.validate()
+	if err != nil {
+		log.Fatalf("Invalid jump configuration: %v", err)
+	}
 }
 
-func (c *DataModeConfiguration) validate() {
+func (c *Configuration) validate() error {
+	validators := []func () error{
+		c.Data.validate,
+		c.Database.validate,
+		c.Trigger.validate,
+		c.Notifications.validate,
+		c.Risk.validate,
+		c.Download.validate,
+		c.History.validate,
+	}
+	for _, validate := range validators {
+		err := validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *DataModeConfiguration) validate() error {
 	if c.TagSlugs == nil {
-		log.Fatalf("Tag slugs missing from data mode configuration")
+		return fmt.Errorf("tag slugs missing from data mode configuration")
 	}
 	minVolumeMin := decimal.NewFromInt(1000)
 	if c.MinVolume == nil || c.MinVolume.LessThan(minVolumeMin) {
-		log.Fatalf("Invalid min volume in data mode configuration")
+		return fmt.Errorf("invalid min volume in data mode configuration")
 	}
 	if c.BufferTimeSpan == nil || *c.BufferTimeSpan < 3600 {
-		log.Fatalf("Invalid buffer time span in data mode configuration")
+		return fmt.Errorf("invalid buffer time span in data mode configuration")
 	}
+	return nil
 }
 
-func (c *TriggerModeConfiguration) validate() {
+func (c *TriggerModeConfiguration) validate() error {
 	if c.Live == nil {
-		log.Fatalf("Live flag missing from configuration")
+		return fmt.Errorf("live flag missing from configuration")
 	}
 	if c.RecordData == nil {
-		log.Fatalf("Record data flag missing from configuration")
+		return fmt.Errorf("record data flag missing from configuration")
 	}
-	for _, trigger := range c.Triggers {
-		trigger.validate()
+	if c.Paper == nil {
+		paper := false
+		c.Paper = &paper
 	}
+	if *c.Live && *c.Paper {
+		return fmt.Errorf("trigger mode can't be live and paper trading at the same time")
+	}
+	for i := range c.Triggers {
+		err := c.Triggers[i].validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (c *JumpConfiguration) validate() {
+func (c *JumpConfiguration) validate() error {
 	thresholds := []*SerializableDecimal{
 		c.Threshold1,
 		c.Threshold2,
@@ -153,52 +277,264 @@ func (c *JumpConfiguration) validate() {
 	priceMax := decimalConstant("1.0")
 	for _, threshold := range thresholds {
 		if threshold == nil {
-			log.Fatalf("Missing threshold in jump configuration")
+			return fmt.Errorf("missing threshold in jump configuration")
 		}
 		if threshold.LessThanOrEqual(priceMin) || threshold.GreaterThanOrEqual(priceMax) {
-			log.Fatalf("Invalid threshold in jump configuration")
+			return fmt.Errorf("invalid threshold in jump configuration")
 		}
 	}
 	if c.SpreadLimit == nil {
-		log.Fatalf("Spread limit missing from jump configuration")
+		return fmt.Errorf("spread limit missing from jump configuration")
 	}
 	if c.SpreadLimit.IsNegative() {
-		log.Fatalf("Spread limit can't be negative")
+		return fmt.Errorf("spread limit can't be negative")
+	}
+	if c.AutoTrade == nil {
+		autoTrade := false
+		c.AutoTrade = &autoTrade
+	}
+	if *c.AutoTrade {
+		if c.Live == nil {
+			return fmt.Errorf("live flag missing from jump configuration")
+		}
+		if c.Paper == nil {
+			paper := false
+			c.Paper = &paper
+		}
+		if *c.Live && *c.Paper {
+			return fmt.Errorf("jump system can't be live and paper trading at the same time")
+		}
+		if c.Size == nil || !c.Size.IsPositive() {
+			return fmt.Errorf("invalid size in jump configuration")
+		}
+		if c.HoldingTime == nil || *c.HoldingTime <= 0 {
+			return fmt.Errorf("invalid holding time in jump configuration")
+		}
+		if c.EntryTimeout == nil {
+			entryTimeout := jumpEntryTimeout
+			c.EntryTimeout = &entryTimeout
+		}
+		if *c.EntryTimeout <= 0 {
+			return fmt.Errorf("invalid entry timeout in jump configuration")
+		}
+		if c.StopLoss == nil {
+			return fmt.Errorf("stop-loss flag missing from jump configuration")
+		}
+	}
+	return nil
+}
+
+func (c *PaperConfiguration) validate() error {
+	if c.Ledger == nil {
+		return fmt.Errorf("ledger path missing from paper trading configuration")
+	}
+	if c.InitialCash == nil || !c.InitialCash.IsPositive() {
+		return fmt.Errorf("invalid initial cash in paper trading configuration")
+	}
+	return nil
+}
+
+func (c *NotificationConfiguration) validate() error {
+	if c.Beep == nil {
+		beep := true
+		c.Beep = &beep
+	}
+	if c.Desktop == nil {
+		desktop := false
+		c.Desktop = &desktop
+	}
+	if c.Webhook != nil {
+		if c.Webhook.URL == nil {
+			return fmt.Errorf("URL missing from webhook configuration")
+		}
+		if c.Webhook.Timeout == nil {
+			timeout := 10
+			c.Webhook.Timeout = &timeout
+		}
+		if *c.Webhook.Timeout <= 0 {
+			return fmt.Errorf("invalid timeout in webhook configuration")
+		}
+	}
+	if c.Email != nil {
+		if c.Email.Host == nil {
+			return fmt.Errorf("host missing from email configuration")
+		}
+		if c.Email.Port == nil || *c.Email.Port <= 0 {
+			return fmt.Errorf("invalid port in email configuration")
+		}
+		if c.Email.From == nil {
+			return fmt.Errorf("sender missing from email configuration")
+		}
+		if len(c.Email.To) == 0 {
+			return fmt.Errorf("recipients missing from email configuration")
+		}
+	}
+	return nil
+}
+
+func (c *DownloadConfiguration) validate() error {
+	if c.Workers == nil {
+		workers := downloadWorkers
+		c.Workers = &workers
+	}
+	if c.RequestsPerSecond == nil {
+		requestsPerSecond := downloadRequestsPerSecond
+		c.RequestsPerSecond = &requestsPerSecond
+	}
+	if c.Retries == nil {
+		retries := downloadRetries
+		c.Retries = &retries
 	}
+	if c.Cursor == nil {
+		cursor := downloadCursor
+		c.Cursor = &cursor
+	}
+	if *c.Workers < 1 {
+		return fmt.Errorf("invalid number of workers in download configuration")
+	}
+	if *c.RequestsPerSecond <= 0 {
+		return fmt.Errorf("invalid request rate in download configuration")
+	}
+	if *c.Retries < 0 {
+		return fmt.Errorf("invalid number of retries in download configuration")
+	}
+	return nil
 }
 
-func (t *Trigger) validate() {
+func (c *HistoryConfiguration) validate() error {
+	if c.Start == nil {
+		start := commons.SerializableDate{
+			Time: commons.MustParseTime(historyStartDateMin),
+		}
+		c.Start = &start
+	}
+	if c.End != nil && !c.End.After(c.Start.Time) {
+		return fmt.Errorf("history end date must be after the start date")
+	}
+	if c.Order == nil {
+		order := historyOrder
+		c.Order = &order
+	}
+	if c.MaxMarkets == nil {
+		maxMarkets := historyMaxOffset
+		c.MaxMarkets = &maxMarkets
+	}
+	if *c.MaxMarkets < 1 {
+		return fmt.Errorf("invalid max markets in history configuration")
+	}
+	if len(c.Fidelities) == 0 {
+		c.Fidelities = []int{
+			historyFidelity,
+		}
+	}
+	for _, fidelity := range c.Fidelities {
+		if fidelity < 1 {
+			return fmt.Errorf("invalid fidelity in history configuration: %d", fidelity)
+		}
+	}
+	return nil
+}
+
+func (c *RiskConfiguration) validate() error {
+	limits := []*SerializableDecimal{
+		c.MaxOrderNotional,
+		c.MaxMarketPosition,
+		c.MaxTagExposure,
+		c.MaxDailyLoss,
+	}
+	for _, limit := range limits {
+		if limit != nil && !limit.IsPositive() {
+			return fmt.Errorf("invalid limit in risk configuration")
+		}
+	}
+	if c.Breach == nil {
+		breach := riskBreachReject
+		c.Breach = &breach
+	}
+	breachActions := []string{
+		riskBreachReject,
+		riskBreachFreeze,
+		riskBreachFlatten,
+	}
+	if !commons.Contains(breachActions, *c.Breach) {
+		return fmt.Errorf("invalid breach action in risk configuration: %s", *c.Breach)
+	}
+	return nil
+}
+
+func (t *Trigger) validate() error {
 	if t.Slug == nil {
-		log.Fatalf("Slug missing from trigger configuration")
+		return fmt.Errorf("slug missing from trigger configuration")
 	}
 	priceMin := decimal.Zero
 	priceMax := decimalConstant("1.0")
 	if t.TakeProfit != nil {
 		if t.TakeProfit.LessThanOrEqual(priceMin) || t.TakeProfit.GreaterThanOrEqual(priceMax) {
-			log.Fatalf("Invalid take profit price in trigger configuration")
+			return fmt.Errorf("invalid take profit price in trigger configuration")
 		}
-		if t.StopLoss.GreaterThanOrEqual(t.TakeProfit.Decimal) {
-			log.Fatalf("Stop-loss must be less than take profit price")
+		if t.StopLoss != nil && t.StopLoss.GreaterThanOrEqual(t.TakeProfit.Decimal) {
+			return fmt.Errorf("stop-loss must be less than take profit price")
 		}
 		if t.TakeProfitLimit == nil || t.TakeProfitLimit.LessThanOrEqual(priceMin) || t.TakeProfitLimit.GreaterThanOrEqual(priceMax) {
-			log.Fatalf("Invalid take profit limit in trigger configuration")
+			return fmt.Errorf("invalid take profit limit in trigger configuration")
 		}
 	}
-	if t.StopLoss == nil || t.StopLoss.LessThanOrEqual(priceMin) || t.StopLoss.GreaterThanOrEqual(priceMax) {
-		log.Fatalf("Invalid stop-loss price in trigger configuration")
+	if t.StopLoss == nil && t.TrailingStop == nil && t.ExitTime == nil && t.ExitBeforeEnd == nil {
+		return fmt.Errorf("trigger \"%s\" requires a stop-loss, a trailing stop or a time-based exit", *t.Slug)
 	}
-	if t.StopLossLimit == nil || t.StopLossLimit.LessThanOrEqual(priceMin) || t.StopLossLimit.GreaterThanOrEqual(priceMax) {
-		log.Fatalf("Invalid stop-loss limit in trigger configuration")
+	if t.StopLoss != nil {
+		if t.StopLoss.LessThanOrEqual(priceMin) || t.StopLoss.GreaterThanOrEqual(priceMax) {
+			return fmt.Errorf("invalid stop-loss price in trigger configuration")
+		}
+		if t.StopLossLimit == nil || t.StopLossLimit.LessThanOrEqual(priceMin) || t.StopLossLimit.GreaterThanOrEqual(priceMax) {
+			return fmt.Errorf("invalid stop-loss limit in trigger configuration")
+		}
+	}
+	if t.TrailingStop != nil && (t.TrailingStop.LessThanOrEqual(priceMin) || t.TrailingStop.GreaterThanOrEqual(priceMax)) {
+		return fmt.Errorf("invalid trailing stop distance in trigger configuration")
+	}
+	if t.ExitBeforeEnd != nil && *t.ExitBeforeEnd <= 0 {
+		return fmt.Errorf("invalid exit before end in trigger configuration")
+	}
+	if t.ExitSlippage == nil {
+		t.ExitSlippage = &SerializableDecimal{
+			Decimal: decimal.Zero,
+		}
+	}
+	if t.ExitSlippage.IsNegative() {
+		return fmt.Errorf("exit slippage can't be negative")
+	}
+	if t.Bracket == nil {
+		bracket := false
+		t.Bracket = &bracket
 	}
+	if *t.Bracket && t.TakeProfit == nil {
+		return fmt.Errorf("bracket orders require a take profit price")
+	}
+	return nil
 }
 
-func (c *DatabaseConfiguration) validate() {
-	if c.URI == nil {
-		log.Fatalf("MongoDB URI missing in configuration file")
+func (c *DatabaseConfiguration) validate() error {
+	if c.Backend == nil {
+		backend := storageMongo
+		c.Backend = &backend
 	}
-	if c.Database == nil {
-		log.Fatalf("MongoDB database missing in configuration file")
+	switch *c.Backend {
+	case storageMongo:
+		if c.URI == nil {
+			return fmt.Errorf("MongoDB URI missing in configuration file")
+		}
+		if c.Database == nil {
+			return fmt.Errorf("MongoDB database missing in configuration file")
+		}
+	case storageFile:
+		if c.Path == nil {
+			return fmt.Errorf("storage path missing in database configuration")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend in database configuration: %s", *c.Backend)
 	}
+	return nil
 }
 
 func (d *SerializableDecimal) UnmarshalYAML(value *yaml.Node) error {
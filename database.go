@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/emirpasic/gods/maps/treemap"
@@ -26,6 +27,8 @@ const (
 	priceChangeCollection = "price_changes"
 	lastTradePriceCollection = "last_trade_prices"
 	historyCollection = "history"
+	gapCollection = "collection_gaps"
+	eventCollection = "events"
 )
 
 type databaseClient struct {
@@ -37,13 +40,19 @@ type databaseClient struct {
 	priceChanges *mongo.Collection
 	lastTradePrices *mongo.Collection
 	history *mongo.Collection
+	histories map[int]*mongo.Collection
+	historyMutex sync.Mutex
+	gaps *mongo.Collection
+	events *mongo.Collection
 	priceChangeBuffer []PriceChangeBSON
+	wal *writeAheadLog
 }
 
 type MarketBSON struct {
 	Slug string `bson:"slug"`
 	Event string `bson:"event"`
 	AssetID string `bson:"asset_id"`
+	ConditionID string `bson:"condition_id"`
 	NegRisk bool `bson:"neg_risk"`
 	Added time.Time `bson:"added"`
 }
@@ -55,6 +64,7 @@ type MarketVolume struct {
 }
 
 type BookEvent struct {
+	ID bson.ObjectID `bson:"_id,omitempty"`
 	AssetID string `bson:"asset_id"`
 	ServerTime time.Time `bson:"server_time"`
 	LocalTime time.Time `bson:"local_time"`
@@ -63,6 +73,7 @@ type BookEvent struct {
 }
 
 type PriceChangeBSON struct {
+	ID bson.ObjectID `bson:"_id,omitempty"`
 	AssetID string `bson:"asset_id"`
 	ServerTime time.Time `bson:"server_time"`
 	LocalTime time.Time `bson:"local_time"`
@@ -74,6 +85,7 @@ type PriceChangeBSON struct {
 }
 
 type LastTradePrice struct {
+	ID bson.ObjectID `bson:"_id,omitempty"`
 	AssetID string `bson:"asset_id"`
 	ServerTime time.Time `bson:"server_time"`
 	LocalTime time.Time `bson:"local_time"`
@@ -90,16 +102,47 @@ type PriceLevel struct {
 	Size bson.Decimal128 `bson:"size"`
 }
 
+type CollectionGap struct {
+	ID bson.ObjectID `bson:"_id,omitempty"`
+	AssetIDs []string `bson:"asset_ids"`
+	Disconnected time.Time `bson:"disconnected"`
+	Reconnected *time.Time `bson:"reconnected"`
+	Reason string `bson:"reason"`
+}
+
+type EventBSON struct {
+	EventID string `bson:"eventId"`
+	Slug string `bson:"slug"`
+	Title string `bson:"title"`
+	NegRisk bool `bson:"negRisk"`
+	Closed bool `bson:"closed"`
+	Tags []string `bson:"tags"`
+	Markets []string `bson:"markets"`
+	Updated time.Time `bson:"updated"`
+}
+
 type PriceHistoryBSON struct {
 	Slug string `bson:"slug"`
+	EventID string `bson:"eventId"`
+	EventSlug string `bson:"eventSlug"`
 	NegRisk bool `bson:"negRisk"`
 	Closed bool `bson:"closed"`
+	Fidelity int `bson:"fidelity"`
 	StartDate time.Time `bson:"startDate"`
 	EndDate *time.Time `bson:"endDate"`
 	Volume float64 `bson:"volume"`
 	Outcome *bool `bson:"outcome"`
 	Tags []string `bson:"tags"`
 	History []PriceHistorySampleBSON `bson:"history"`
+	NoHistory []PriceHistorySampleBSON `bson:"noHistory"`
+	NoFetched bool `bson:"noFetched"`
+}
+
+type priceHistoryStatus struct {
+	exists bool
+	complete bool
+	lastTimestamp *time.Time
+	lastNoTimestamp *time.Time
 }
 
 type PriceHistorySampleBSON struct {
@@ -107,20 +150,22 @@ type PriceHistorySampleBSON struct {
 	Price float64 `bson:"price"`
 }
 
-func newDatabaseClient() databaseClient {
-	clientOptions := options.Client().ApplyURI(*configuration.Database.URI)
+func newDatabaseClient() *databaseClient {
+	clientOptions := options.Client().ApplyURI(*getConfiguration().Database.URI)
 	client, err := mongo.Connect(clientOptions)
 	if err != nil {
 		log.Fatal(err)
 	}
-	database := client.Database(*configuration.Database.Database)
+	database := client.Database(*getConfiguration().Database.Database)
 	markets := database.Collection(marketCollection)
 	marketVolume := database.Collection(marketVolumeCollection)
 	bookEvents := database.Collection(bookEventCollection)
 	priceChanges := database.Collection(priceChangeCollection)
 	lastTradePrices := database.Collection(lastTradePriceCollection)
 	history := database.Collection(historyCollection)
-	dbClient := databaseClient{
+	gaps := database.Collection(gapCollection)
+	events := database.Collection(eventCollection)
+	dbClient := &databaseClient{
 		client: client,
 		database: database,
 		markets: markets,
@@ -129,7 +174,11 @@ func newDatabaseClient() databaseClient {
 		priceChanges: priceChanges,
 		lastTradePrices: lastTradePrices,
 		history: history,
+		histories: map[int]*mongo.Collection{},
+		gaps: gaps,
+		events: events,
 		priceChangeBuffer: []PriceChangeBSON{},
+		wal: nil,
 	}
 	dbClient.createIndexes()
 	return dbClient
@@ -138,7 +187,9 @@ func newDatabaseClient() databaseClient {
 func (c *databaseClient) createIndexes() {	
 	c.createMarketIndexes()
 	c.createChannelIndexes()
-	c.createHistoryIndexes()
+	c.createHistoryIndexes(c.history)
+	c.createGapIndexes()
+	c.createEventIndexes()
 }
 
 func (c *databaseClient) createMarketIndexes() {
@@ -182,7 +233,7 @@ func (c *databaseClient) createChannelIndexes() {
 	}
 }
 
-func (c *databaseClient) createHistoryIndexes() {
+func (c *databaseClient) createHistoryIndexes(history *mongo.Collection) {
 	slugKey := bson.D{
 		{Key: "slug", Value: 1},
 	}
@@ -190,7 +241,7 @@ func (c *databaseClient) createHistoryIndexes() {
 		Keys: slugKey,
 		Options: options.Index().SetUnique(true),
 	}
-	createIndex(c.history, slugIndex)
+	createIndex(history, slugIndex)
 	closedKey := bson.D{
 		{Key: "closed", Value: -1},
 		{Key: "negRisk", Value: 1},
@@ -200,7 +251,53 @@ func (c *databaseClient) createHistoryIndexes() {
 		Keys: closedKey,
 		Options: options.Index().SetUnique(true),
 	}
-	createIndex(c.history, closedIndex)
+	createIndex(history, closedIndex)
+}
+
+func (c *databaseClient) getHistory(fidelity int) *mongo.Collection {
+	if fidelity == historyFidelity {
+		return c.history
+	}
+	c.historyMutex.Lock()
+	defer c.historyMutex.Unlock()
+	history, exists := c.histories[fidelity]
+	if !exists {
+		history = c.database.Collection(getHistoryCollection(fidelity))
+		c.createHistoryIndexes(history)
+		c.histories[fidelity] = history
+	}
+	return history
+}
+
+func (c *databaseClient) createEventIndexes() {
+	slugKey := bson.D{
+		{Key: "slug", Value: 1},
+	}
+	slugIndex := mongo.IndexModel{
+		Keys: slugKey,
+		Options: options.Index().SetUnique(true),
+	}
+	createIndex(c.events, slugIndex)
+}
+
+func (c *databaseClient) createGapIndexes() {
+	keys := bson.D{
+		{Key: "disconnected", Value: 1},
+	}
+	indexModel := mongo.IndexModel{
+		Keys: keys,
+	}
+	createIndex(c.gaps, indexModel)
+}
+
+func (c *databaseClient) enableWAL(directory string) {
+	collections := []*mongo.Collection{
+		c.bookEvents,
+		c.priceChanges,
+		c.lastTradePrices,
+	}
+	c.wal = newWriteAheadLog(directory, collections)
+	log.Printf("Writing channel data to write-ahead log %s", directory)
 }
 
 func (c *databaseClient) close() {
@@ -210,31 +307,7 @@ func (c *databaseClient) close() {
 }
 
 func (c *databaseClient) insertMarkets(markets []gamma.Market, assetIDs []string, eventSlugMap map[string]string) {
-	dbMarkets := []MarketBSON{}
-	dbVolume := []MarketVolume{}
-	now := time.Now()
-	for i, market := range markets {
-		event, exists := eventSlugMap[market.Slug]
-		if !exists {
-			log.Printf("Warning: unable to determine event slug for market %s", market.Slug)
-			continue
-		}
-		assetID := assetIDs[i]
-		dbMarket := MarketBSON{
-			Slug: market.Slug,
-			Event: event,
-			AssetID: assetID,
-			NegRisk: market.NegRisk,
-			Added: now,
-		}
-		dbMarkets = append(dbMarkets, dbMarket)
-		volume := MarketVolume{
-			Slug: market.Slug,
-			Timestamp: now,
-			Volume: market.Volume24Hr,
-		}
-		dbVolume = append(dbVolume, volume)
-	}
+	dbMarkets, dbVolume := getMarketDocuments(markets, assetIDs, eventSlugMap)
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
 	ordered := options.InsertMany().SetOrdered(false)
@@ -257,62 +330,31 @@ func (c *databaseClient) insertBookMessage(message gamma.BookMessage, subscripti
 }
 
 func (c *databaseClient) insertBookEvent(message gamma.BookMessage) {
-	serverTime, err := convertTimestampString(message.Timestamp)
-	if err != nil {
-		return
-	}
-	localTime := time.Now()
-	bids, err := convertOrderSummaries(message.Bids)
+	bookEvent, err := newBookEvent(message)
 	if err != nil {
 		return
 	}
-	asks, err := convertOrderSummaries(message.Asks)
-	if err != nil {
+	if c.wal != nil {
+		c.wal.append(c.bookEvents, bookEvent)
 		return
 	}
-	bookEvent := BookEvent{
-		AssetID: message.AssetID,
-		ServerTime: serverTime,
-		LocalTime: localTime,
-		Bids: bids,
-		Asks: asks,
-	}
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
-	_, insertErr := c.bookEvents.InsertOne(ctx, bookEvent)
-	if insertErr != nil {
+	_, err = c.bookEvents.InsertOne(ctx, bookEvent)
+	if err != nil {
 		log.Printf("Warning: failed to insert book event into database: %v", err)
 	}
 }
 
 func (c *databaseClient) insertPriceChange(message gamma.BookMessage) {
-	serverTime, err := convertTimestampString(message.Timestamp)
+	priceChanges, err := newPriceChanges(message)
 	if err != nil {
 		return
 	}
-	localTime := time.Now()
-	for _, change := range message.PriceChanges {
-		price, size, err := convertPriceSize(change.Price, change.Size)
-		if err != nil {
-			return
-		}
-		bestBid, bestAsk, err := convertPriceSize(change.BestBid, change.BestAsk)
-		if err != nil {
-			return
-		}
-		buy, err := convertSide(change.Side)
-		if err != nil {
-			return
-		}
-		priceChange := PriceChangeBSON{
-			AssetID: change.AssetID,
-			ServerTime: serverTime,
-			LocalTime: localTime,
-			Price: price,
-			Size: size,
-			Buy: buy,
-			BestBid: bestBid,
-			BestAsk: bestAsk,
+	for _, priceChange := range priceChanges {
+		if c.wal != nil {
+			c.wal.append(c.priceChanges, priceChange)
+			continue
 		}
 		c.priceChangeBuffer = append(c.priceChangeBuffer, priceChange)
 	}
@@ -322,92 +364,95 @@ func (c *databaseClient) insertPriceChange(message gamma.BookMessage) {
 }
 
 func (c *databaseClient) insertLastTradePrice(message gamma.BookMessage, subscription marketSubscription) {
-	serverTime, err := convertTimestampString(message.Timestamp)
+	lastTradePrice, err := newLastTradePrice(message, subscription)
 	if err != nil {
 		return
 	}
-	localTime := time.Now()
-	price, size, err := convertPriceSize(message.Price, message.Size)
-	if err != nil {
-		return
-	}
-	buy, err := convertSide(message.Side)
-	if err != nil {
-		return
-	}
-	volume, err := convertVolume(subscription)
-	if err != nil {
+	if c.wal != nil {
+		c.wal.append(c.lastTradePrices, lastTradePrice)
 		return
 	}
-	bids := getPriceLevels(subscription.bids, true)
-	asks := getPriceLevels(subscription.asks, false)
-	lastTradePrice := LastTradePrice{
-		AssetID: message.AssetID,
-		ServerTime: serverTime,
-		LocalTime: localTime,
-		Price: price,
-		Size: size,
-		Buy: buy,
-		Volume: volume,
-		Bids: bids,
-		Asks: asks,
-	}
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
-	_, insertErr := c.lastTradePrices.InsertOne(ctx, lastTradePrice)
-	if insertErr != nil {
+	_, err = c.lastTradePrices.InsertOne(ctx, lastTradePrice)
+	if err != nil {
 		log.Printf("Warning: failed to insert last trade price into database: %v", err)
 	}
 }
 
-func (c *databaseClient) priceHistoryCheck(slug string) (bool, bool) {
+func (c *databaseClient) priceHistoryCheck(slug string, fidelity int) priceHistoryStatus {
 	filter := bson.M{
 		"slug": slug,
 	}
 	projection := bson.M{
 		"closed": 1,
-	}
-	var result bson.M
+		"eventId": 1,
+		"noFetched": 1,
+		"history": bson.M{
+			"$slice": -1,
+		},
+		"noHistory": bson.M{
+			"$slice": -1,
+		},
+	}
+	var result PriceHistoryBSON
 	opts := options.FindOne().SetProjection(projection)
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
-	err := c.history.FindOne(ctx, filter, opts).Decode(&result)
+	err := c.getHistory(fidelity).FindOne(ctx, filter, opts).Decode(&result)
 	if err == mongo.ErrNoDocuments {
-		return false, false
+		return getMissingHistoryStatus(false)
 	} else if err != nil {
 		log.Printf("Failed to determine if price history exists: %v", err)
-		return true, true
+		return getMissingHistoryStatus(true)
 	} else {
-		closed, ok := result["closed"].(bool)
-		if !ok {
-			log.Fatalf("Failed to read closed field for %s", slug)
-		}
-		return true, closed
+		return getPriceHistoryStatus(result)
 	}
 }
 
-func (c *databaseClient) deletePriceHistory(slug string) {
+func (c *databaseClient) updatePriceHistory(history PriceHistoryBSON) {
 	filter := bson.M{
-		"slug": slug,
+		"slug": history.Slug,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"eventId": history.EventID,
+			"eventSlug": history.EventSlug,
+			"negRisk": history.NegRisk,
+			"closed": history.Closed,
+			"endDate": history.EndDate,
+			"volume": history.Volume,
+			"outcome": history.Outcome,
+			"tags": history.Tags,
+			"noFetched": history.NoFetched,
+		},
+		"$push": bson.M{
+			"history": bson.M{
+				"$each": history.History,
+			},
+			"noHistory": bson.M{
+				"$each": history.NoHistory,
+			},
+		},
 	}
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
-	_, err := c.history.DeleteOne(ctx, filter)
+	_, err := c.getHistory(history.Fidelity).UpdateOne(ctx, filter, update)
 	if err != nil {
-		log.Fatalf("Failed to delete price history for %s: %v", slug, err)
+		log.Printf("Warning: failed to update price history of %s: %v", history.Slug, err)
 	}
 }
 
 func (c *databaseClient) insertPriceHistory(history PriceHistoryBSON) {
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
-	_, err := c.history.InsertOne(ctx, history)
+	_, err := c.getHistory(history.Fidelity).InsertOne(ctx, history)
 	if err != nil {
 		log.Printf("Warning: failed to insert price history into database: %v", err)
 	}
 }
 
-func (c *databaseClient) getPriceHistoryData(closed *bool, negRisk *bool, minVolume *float64, tag *string) []PriceHistoryBSON {
+func (c *databaseClient) getPriceHistoryData(fidelity int, closed *bool, negRisk *bool, minVolume *float64, tag *string) []PriceHistoryBSON {
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
 	filter := bson.M{}
@@ -425,7 +470,7 @@ func (c *databaseClient) getPriceHistoryData(closed *bool, negRisk *bool, minVol
 	if tag != nil {
 		filter["tags"] = *tag
 	}
-	cursor, err := c.history.Find(ctx, filter)
+	cursor, err := c.getHistory(fidelity).Find(ctx, filter)
 	if err != nil {
 		log.Fatalf("Failed to read price history data: %v", err)
 	}
@@ -437,7 +482,7 @@ func (c *databaseClient) getPriceHistoryData(closed *bool, negRisk *bool, minVol
 	return historyData
 }
 
-func (c *databaseClient) getTagsOnly() []PriceHistoryBSON {
+func (c *databaseClient) getTagsOnly(fidelity int) []PriceHistoryBSON {
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
 	filter := bson.M{}
@@ -446,7 +491,7 @@ func (c *databaseClient) getTagsOnly() []PriceHistoryBSON {
 		"tags": 1,
 	}
 	opts := options.Find().SetProjection(projection)
-	cursor, err := c.history.Find(ctx, filter, opts)
+	cursor, err := c.getHistory(fidelity).Find(ctx, filter, opts)
 	if err != nil {
 		log.Fatalf("Failed to read price history data: %v", err)
 	}
@@ -458,6 +503,34 @@ func (c *databaseClient) getTagsOnly() []PriceHistoryBSON {
 	return historyData
 }
 
+func (c *databaseClient) upsertEvent(event EventBSON) {
+	filter := bson.M{
+		"slug": event.Slug,
+	}
+	opts := options.Replace().SetUpsert(true)
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	_, err := c.events.ReplaceOne(ctx, filter, event, opts)
+	if err != nil {
+		log.Printf("Warning: failed to update event %s: %v", event.Slug, err)
+	}
+}
+
+func (c *databaseClient) getEvents() []EventBSON {
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	cursor, err := c.events.Find(ctx, bson.M{})
+	if err != nil {
+		log.Fatalf("Failed to read events: %v", err)
+	}
+	defer cursor.Close(ctx)
+	var events []EventBSON
+	if err := cursor.All(ctx, &events); err != nil {
+		log.Fatalf("Failed to iterate over cursor: %v", err)
+	}
+	return events
+}
+
 func (c *databaseClient) getMarkets() []MarketBSON {
 	ctx, cancel := getDatabaseContext()
 	defer cancel()
@@ -474,7 +547,146 @@ func (c *databaseClient) getMarkets() []MarketBSON {
 	return markets
 }
 
+func (c *databaseClient) insertGap(gap *CollectionGap) {
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	result, err := c.gaps.InsertOne(ctx, gap)
+	if err != nil {
+		log.Printf("Failed to insert collection gap: %v", err)
+		return
+	}
+	gap.ID = result.InsertedID.(bson.ObjectID)
+}
+
+func (c *databaseClient) closeGap(gap *CollectionGap, reconnected time.Time) {
+	gap.Reconnected = &reconnected
+	if gap.ID.IsZero() {
+		return
+	}
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	update := bson.M{
+		"$set": bson.M{
+			"reconnected": reconnected,
+		},
+	}
+	_, err := c.gaps.UpdateByID(ctx, gap.ID, update)
+	if err != nil {
+		log.Printf("Failed to update collection gap: %v", err)
+	}
+}
+
+func (c *databaseClient) getGapsInRange(start, end time.Time) []CollectionGap {
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	filter := bson.M{
+		"disconnected": bson.M{
+			"$lt": end,
+		},
+		"$or": bson.A{
+			bson.M{
+				"reconnected": nil,
+			},
+			bson.M{
+				"reconnected": bson.M{
+					"$gt": start,
+				},
+			},
+		},
+	}
+	sort := bson.D{
+		{Key: "disconnected", Value: 1},
+	}
+	opts := options.Find().SetSort(sort)
+	cursor, err := c.gaps.Find(ctx, filter, opts)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", c.gaps.Name(), err)
+	}
+	defer cursor.Close(ctx)
+	var gaps []CollectionGap
+	if err := cursor.All(ctx, &gaps); err != nil {
+		log.Fatalf("Failed to iterate over cursor: %v", err)
+	}
+	return gaps
+}
+
+func (c *databaseClient) getBookEvents(assetID string) []BookEvent {
+	var bookEvents []BookEvent
+	c.findByAssetID(c.bookEvents, assetID, &bookEvents)
+	return bookEvents
+}
+
+func (c *databaseClient) getPriceChanges(assetID string) []PriceChangeBSON {
+	var priceChanges []PriceChangeBSON
+	c.findByAssetID(c.priceChanges, assetID, &priceChanges)
+	return priceChanges
+}
+
+func (c *databaseClient) getBookEventsInRange(start, end time.Time) []BookEvent {
+	var bookEvents []BookEvent
+	c.findInRange(c.bookEvents, start, end, &bookEvents)
+	return bookEvents
+}
+
+func (c *databaseClient) getLastTradePricesInRange(start, end time.Time) []LastTradePrice {
+	var lastTradePrices []LastTradePrice
+	c.findInRange(c.lastTradePrices, start, end, &lastTradePrices)
+	return lastTradePrices
+}
+
+func (c *databaseClient) getPriceChangesInRange(start, end time.Time) []PriceChangeBSON {
+	var priceChanges []PriceChangeBSON
+	c.findInRange(c.priceChanges, start, end, &priceChanges)
+	return priceChanges
+}
+
+func (c *databaseClient) findByAssetID(collection *mongo.Collection, assetID string, results any) {
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	filter := bson.M{
+		"asset_id": assetID,
+	}
+	sort := bson.D{
+		{Key: "server_time", Value: 1},
+	}
+	opts := options.Find().SetSort(sort)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", collection.Name(), err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, results); err != nil {
+		log.Fatalf("Failed to iterate over cursor: %v", err)
+	}
+}
+
+func (c *databaseClient) findInRange(collection *mongo.Collection, start, end time.Time, results any) {
+	ctx, cancel := getDatabaseContext()
+	defer cancel()
+	filter := bson.M{
+		"server_time": bson.M{
+			"$gte": start,
+			"$lt": end,
+		},
+	}
+	sort := bson.D{
+		{Key: "server_time", Value: 1},
+	}
+	opts := options.Find().SetSort(sort)
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", collection.Name(), err)
+	}
+	defer cursor.Close(ctx)
+	if err := cursor.All(ctx, results); err != nil {
+		log.Fatalf("Failed to iterate over cursor: %v", err)
+	}
+}
+
 func (c *databaseClient) flushBuffer() {
+	if c.wal != nil {
+		c.wal.sync()
+	}
 	if len(c.priceChangeBuffer) == 0 {
 		return
 	}
@@ -487,6 +699,158 @@ func (c *databaseClient) flushBuffer() {
 	c.priceChangeBuffer = c.priceChangeBuffer[:0]
 }
 
+func getMarketDocuments(markets []gamma.Market, assetIDs []string, eventSlugMap map[string]string) ([]MarketBSON, []MarketVolume) {
+	dbMarkets := []MarketBSON{}
+	dbVolume := []MarketVolume{}
+	now := time.Now()
+	for i, market := range markets {
+		event, exists := eventSlugMap[market.Slug]
+		if !exists {
+			log.Printf("Warning: unable to determine event slug for market %s", market.Slug)
+			continue
+		}
+		assetID := assetIDs[i]
+		dbMarket := MarketBSON{
+			Slug: market.Slug,
+			Event: event,
+			AssetID: assetID,
+			ConditionID: market.ConditionID,
+			NegRisk: market.NegRisk,
+			Added: now,
+		}
+		dbMarkets = append(dbMarkets, dbMarket)
+		volume := MarketVolume{
+			Slug: market.Slug,
+			Timestamp: now,
+			Volume: market.Volume24Hr,
+		}
+		dbVolume = append(dbVolume, volume)
+	}
+	return dbMarkets, dbVolume
+}
+
+func getPriceHistoryStatus(history PriceHistoryBSON) priceHistoryStatus {
+	return priceHistoryStatus{
+		exists: true,
+		complete: history.Closed && history.NoFetched && history.EventID != "",
+		lastTimestamp: getLastSampleTimestamp(history.History),
+		lastNoTimestamp: getLastSampleTimestamp(history.NoHistory),
+	}
+}
+
+func getMissingHistoryStatus(failed bool) priceHistoryStatus {
+	return priceHistoryStatus{
+		exists: failed,
+		complete: failed,
+		lastTimestamp: nil,
+		lastNoTimestamp: nil,
+	}
+}
+
+func getLastSampleTimestamp(samples []PriceHistorySampleBSON) *time.Time {
+	if len(samples) == 0 {
+		return nil
+	}
+	timestamp := samples[len(samples) - 1].Timestamp
+	return &timestamp
+}
+
+func newBookEvent(message gamma.BookMessage) (BookEvent, error) {
+	serverTime, err := convertTimestampString(message.Timestamp)
+	if err != nil {
+		return BookEvent{}, err
+	}
+	localTime := time.Now()
+	bids, err := convertOrderSummaries(message.Bids)
+	if err != nil {
+		return BookEvent{}, err
+	}
+	asks, err := convertOrderSummaries(message.Asks)
+	if err != nil {
+		return BookEvent{}, err
+	}
+	bookEvent := BookEvent{
+		ID: bson.NewObjectID(),
+		AssetID: message.AssetID,
+		ServerTime: serverTime,
+		LocalTime: localTime,
+		Bids: bids,
+		Asks: asks,
+	}
+	return bookEvent, nil
+}
+
+func newPriceChanges(message gamma.BookMessage) ([]PriceChangeBSON, error) {
+	serverTime, err := convertTimestampString(message.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	localTime := time.Now()
+	priceChanges := []PriceChangeBSON{}
+	for _, change := range message.PriceChanges {
+		price, size, err := convertPriceSize(change.Price, change.Size)
+		if err != nil {
+			return nil, err
+		}
+		bestBid, bestAsk, err := convertPriceSize(change.BestBid, change.BestAsk)
+		if err != nil {
+			return nil, err
+		}
+		buy, err := convertSide(change.Side)
+		if err != nil {
+			return nil, err
+		}
+		priceChange := PriceChangeBSON{
+			ID: bson.NewObjectID(),
+			AssetID: change.AssetID,
+			ServerTime: serverTime,
+			LocalTime: localTime,
+			Price: price,
+			Size: size,
+			Buy: buy,
+			BestBid: bestBid,
+			BestAsk: bestAsk,
+		}
+		priceChanges = append(priceChanges, priceChange)
+	}
+	return priceChanges, nil
+}
+
+func newLastTradePrice(message gamma.BookMessage, subscription marketSubscription) (LastTradePrice, error) {
+	serverTime, err := convertTimestampString(message.Timestamp)
+	if err != nil {
+		return LastTradePrice{}, err
+	}
+	localTime := time.Now()
+	price, size, err := convertPriceSize(message.Price, message.Size)
+	if err != nil {
+		return LastTradePrice{}, err
+	}
+	buy, err := convertSide(message.Side)
+	if err != nil {
+		return LastTradePrice{}, err
+	}
+	volume, err := convertVolume(subscription)
+	if err != nil {
+		return LastTradePrice{}, err
+	}
+	bids := getPriceLevels(subscription.bids, true)
+	asks := getPriceLevels(subscription.asks, false)
+	lastTradePrice := LastTradePrice{
+		ID: bson.NewObjectID(),
+		AssetID: message.AssetID,
+		ServerTime: serverTime,
+		LocalTime: localTime,
+		Price: price,
+		Size: size,
+		Buy: buy,
+		Volume: volume,
+		Bids: bids,
+		Asks: asks,
+	}
+	return lastTradePrice, nil
+}
+
 func convertTimestampString(timestamp string) (time.Time, error) {
 	milliseconds, err := strconv.ParseInt(timestamp, 10, 64)
 	if err != nil {
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+type BacktestDefinition struct {
+	Strategy *string `yaml:"strategy"`
+	Start *commons.SerializableDate `yaml:"start"`
+	End *commons.SerializableDate `yaml:"end"`
+	InitialCash *float64 `yaml:"initialCash"`
+	FillModel *string `yaml:"fillModel"`
+	Resolution *string `yaml:"resolution"`
+	Tags []string `yaml:"tags"`
+	ExcludeTags []string `yaml:"excludeTags"`
+	Parameters strategyParameters `yaml:"parameters"`
+	Output BacktestOutput `yaml:"output"`
+	Sweep *SweepDefinition `yaml:"sweep"`
+	WalkForward *WalkForwardDefinition `yaml:"walkForward"`
+}
+
+type BacktestOutput struct {
+	Plot bool `yaml:"plot"`
+	EquityCurve *string `yaml:"equityCurve"`
+	Tags *bool `yaml:"tags"`
+	Hours *bool `yaml:"hours"`
+	Weekdays *bool `yaml:"weekdays"`
+	Prices *bool `yaml:"prices"`
+	RecentTrades *bool `yaml:"recentTrades"`
+}
+
+func runBacktest(path string) {
+	loadConfiguration()
+	definition := loadBacktestDefinition(path)
+	if definition.WalkForward != nil {
+		runWalkForward(definition)
+		return
+	}
+	if definition.Sweep != nil {
+		runSweep(definition)
+		return
+	}
+	strategy := definition.newStrategy(definition.Parameters)
+	start := definition.Start.Time
+	end := definition.End.Time
+	data := definition.loadData()
+	fill := newFillModel(*definition.FillModel)
+	defer fill.close()
+	result := definition.execute(strategy, start, end, fill, data)
+	fmt.Printf("Backtest of strategy \"%s\" (%s):\n", *definition.Strategy, path)
+	result.print(definition.Output.getPrintOptions())
+	dailyEquityCurve := getDailyEquityCurve(result.equityCurve)
+	if definition.Output.EquityCurve != nil {
+		writeJSON(*definition.Output.EquityCurve, dailyEquityCurve)
+	}
+	if definition.Output.Plot {
+		plotData("equity", dailyEquityCurve)
+	}
+}
+
+func loadBacktestDefinition(path string) *BacktestDefinition {
+	var definition *BacktestDefinition
+	definition = commons.LoadConfiguration(path, definition)
+	definition.validate()
+	return definition
+}
+
+func (d *BacktestDefinition) validate() {
+	if d.Strategy == nil {
+		log.Fatalf("Strategy missing from backtest definition")
+	}
+	_, exists := strategyRegistry[*d.Strategy]
+	if !exists {
+		log.Fatalf("Unknown strategy in backtest definition: %s", *d.Strategy)
+	}
+	if d.Start == nil || d.End == nil {
+		log.Fatalf("Start and end dates are required in backtest definition")
+	}
+	if !d.Start.Time.Before(d.End.Time) {
+		log.Fatalf("Start date must precede end date in backtest definition")
+	}
+	if d.InitialCash == nil {
+		initialCash := backtestInitialCash
+		d.InitialCash = &initialCash
+	} else if *d.InitialCash <= 0.0 {
+		log.Fatalf("Invalid initial cash in backtest definition")
+	}
+	if d.FillModel == nil {
+		fillModel := fillModelSynthetic
+		d.FillModel = &fillModel
+	} else if *d.FillModel != fillModelSynthetic && *d.FillModel != fillModelBook {
+		log.Fatalf("Unknown fill model in backtest definition: %s", *d.FillModel)
+	}
+	if d.Resolution == nil {
+		resolution := resolutionHour
+		d.Resolution = &resolution
+	} else if *d.Resolution != resolutionHour && *d.Resolution != resolutionTick {
+		log.Fatalf("Unknown resolution in backtest definition: %s", *d.Resolution)
+	}
+	if d.Parameters == nil {
+		d.Parameters = strategyParameters{}
+	}
+	if d.Sweep != nil {
+		d.Sweep.validate()
+	}
+	if d.WalkForward != nil {
+		d.WalkForward.validate(d)
+	}
+}
+
+func (d *BacktestDefinition) newStrategy(parameters strategyParameters) backtestStrategy {
+	factory := strategyRegistry[*d.Strategy]
+	return factory(d.Tags, d.ExcludeTags, parameters)
+}
+
+func (d *BacktestDefinition) loadData() *backtestDataSet {
+	data := loadBacktestData()
+	if *d.Resolution == resolutionTick {
+		data.ticks = loadBacktestTicks(d.Start.Time, d.End.Time)
+	}
+	return data
+}
+
+func (d *BacktestDefinition) execute(
+	strategy backtestStrategy,
+	start time.Time,
+	end time.Time,
+	fill fillModel,
+	data *backtestDataSet,
+) backtestResult {
+	if *d.Resolution == resolutionTick {
+		tickStrategy, ok := strategy.(tickStrategy)
+		if !ok {
+			log.Fatalf("Strategy \"%s\" does not support tick resolution", *d.Strategy)
+		}
+		return executeTickBacktest(tickStrategy, start, end, *d.InitialCash, fill, data)
+	}
+	return executeBacktest(strategy, start, end, *d.InitialCash, fill, data)
+}
+
+func (o *BacktestOutput) getPrintOptions() backtestPrintOptions {
+	options := getDefaultPrintOptions()
+	if o.Tags != nil {
+		options.tags = *o.Tags
+	}
+	if o.Hours != nil {
+		options.hours = *o.Hours
+	}
+	if o.Weekdays != nil {
+		options.weekdays = *o.Weekdays
+	}
+	if o.Prices != nil {
+		options.prices = *o.Prices
+	}
+	if o.RecentTrades != nil {
+		options.recentTrades = *o.RecentTrades
+	}
+	return options
+}
+
+func writeJSON(path string, data any) {
+	bytes, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		log.Fatalf("Failed to serialize data for %s: %v", path, err)
+	}
+	commons.WriteFile(path, string(bytes))
+	log.Printf("Wrote %s", path)
+}
+
+func plotData(argument string, data any) {
+	arguments := []string{
+		"python/plot.py",
+		argument,
+	}
+	commons.PythonPipe(arguments, data)
+}
\ No newline at end of file
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/encratite/gamma"
+)
+
+const (
+	discoveryInterval = 300
+	discoveryShardLimit = 8
+	discoveryDroppedLimit = 100
+)
+
+func getDataModeMarkets() ([]gamma.Market, map[string]string, error) {
+	markets, eventSlugMap, err := getEventMarkets()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, eventSlug := range getConfiguration().Data.Events {
+		event, err := gamma.GetEventBySlug(eventSlug)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, market := range event.Markets {
+			eventSlugMap[market.Slug] = event.Slug
+			markets = append(markets, market)
+		}
+	}
+	return markets, eventSlugMap, nil
+}
+
+func (s *tradingSystem) discoverMarkets() {
+	for {
+		time.Sleep(time.Duration(discoveryInterval) * time.Second)
+		markets, eventSlugMap, err := getDataModeMarkets()
+		if err != nil {
+			log.Printf("Market discovery failed: %v", err)
+			continue
+		}
+		var previousMarkets []gamma.Market
+		s.loop.invoke(func () {
+			previousMarkets = s.markets
+		})
+		closedMarkets := getClosedMarkets(previousMarkets, markets)
+		var generation int
+		newMarkets := []gamma.Market{}
+		shards := [][]string{}
+		resubscribe := false
+		s.loop.invoke(func () {
+			generation = s.generation
+			for _, market := range markets {
+				exists := commons.ContainsFunc(s.markets, func (m gamma.Market) bool {
+					return m.ConditionID == market.ConditionID
+				})
+				if !exists {
+					newMarkets = append(newMarkets, market)
+					s.markets = append(s.markets, market)
+					delete(s.dropped, market.ConditionID)
+				}
+			}
+			for _, market := range closedMarkets {
+				s.dropMarket(market)
+			}
+			if len(newMarkets) > 0 {
+				s.database.insertMarkets(newMarkets, getAssetIDs(newMarkets), eventSlugMap)
+			}
+			if generation == 0 {
+				return
+			}
+			shards = slices.Collect(slices.Chunk(getAssetIDs(newMarkets), gamma.MarketChannelLimit))
+			if len(s.dropped) > discoveryDroppedLimit || s.shards + len(shards) > discoveryShardLimit {
+				s.generation++
+				resubscribe = true
+				return
+			}
+			s.shards += len(shards)
+		})
+		for _, market := range newMarkets {
+			log.Printf("Discovered new market %s", market.Slug)
+		}
+		if resubscribe {
+			log.Printf("Too many dropped markets or additional subscriptions, resubscribing")
+			continue
+		}
+		for _, assetIDs := range shards {
+			go s.subscribeShard(generation, assetIDs)
+		}
+	}
+}
+
+func getClosedMarkets(previousMarkets, markets []gamma.Market) []gamma.Market {
+	closedMarkets := []gamma.Market{}
+	for _, previousMarket := range previousMarkets {
+		exists := commons.ContainsFunc(markets, func (m gamma.Market) bool {
+			return m.ConditionID == previousMarket.ConditionID
+		})
+		if exists {
+			continue
+		}
+		market, err := gamma.GetMarket(previousMarket.Slug)
+		if err != nil {
+			log.Printf("Failed to check the status of market %s: %v", previousMarket.Slug, err)
+			continue
+		}
+		if market.Closed || !market.Active {
+			closedMarkets = append(closedMarkets, previousMarket)
+		}
+	}
+	return closedMarkets
+}
+
+func (s *tradingSystem) dropMarket(market gamma.Market) {
+	log.Printf("Dropping closed market %s", market.Slug)
+	markets := []gamma.Market{}
+	for _, m := range s.markets {
+		if m.ConditionID != market.ConditionID {
+			markets = append(markets, m)
+		}
+	}
+	s.markets = markets
+	delete(s.subscriptions, market.ConditionID)
+	s.dropped[market.ConditionID] = true
+}
+
+func (s *tradingSystem) subscribeShard(generation int, assetIDs []string) {
+	log.Printf("Subscribing to %d additional markets", len(assetIDs))
+	s.loop.invoke(func () {
+		for _, assetID := range assetIDs {
+			s.assets[assetID] = true
+		}
+	})
+	backoff := newReconnectBackoff()
+	var gap *CollectionGap
+	for {
+		backoff.connect()
+		err := gamma.SubscribeToMarkets(assetIDs, s.getBookCallback(generation, gap))
+		disconnected := time.Now()
+		current := false
+		s.loop.invoke(func () {
+			current = generation == s.generation
+			if current {
+				gap = s.recordGap(gap, assetIDs, disconnected, err)
+			}
+		})
+		if !current {
+			return
+		}
+		if err != nil {
+			log.Printf("Subscription error: %v", err)
+		}
+		backoff.wait()
+	}
+}
\ No newline at end of file
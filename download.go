@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/encratite/commons"
@@ -12,42 +13,75 @@ import (
 
 const (
 	historyFidelitySingle = 1
+	noHistorySuffix = "-no.csv"
 )
 
 func downloadEvent(slug string, directory string) {
-	event, err := gamma.GetEventBySlug(slug)
+	scheduler := newDownloadScheduler()
+	downloadEventMarkets(scheduler, slug, directory)
+	scheduler.printSummary()
+}
+
+func downloadEventMarkets(scheduler *downloadScheduler, slug string, directory string) {
+	markets := []gamma.Market{}
+	err := scheduler.request(slug, func () error {
+		event, err := gamma.GetEventBySlug(slug)
+		if err != nil {
+			return err
+		}
+		markets = event.Markets
+		return nil
+	})
 	if err != nil {
+		scheduler.fail(slug, err)
 		return
 	}
 	commons.CreateDirectory(directory)
-	for _, market := range event.Markets {
+	for _, market := range markets {
 		path := filepath.Join(directory, fmt.Sprintf("%s.csv", market.Slug))
-		if commons.FileExists(path) {
-			continue
-		}
-		startDate, err := commons.ParseTime(market.StartDate)
+		scheduler.submit(market.Slug, func () (bool, error) {
+			return downloadMarketPrices(scheduler, market, path)
+		})
+	}
+}
+
+func downloadMarketPrices(scheduler *downloadScheduler, market gamma.Market, path string) (bool, error) {
+	noPath := strings.TrimSuffix(path, ".csv") + noHistorySuffix
+	if commons.FileExists(path) && commons.FileExists(noPath) {
+		return true, nil
+	}
+	startDate, err := commons.ParseTime(market.StartDate)
+	if err != nil {
+		createdDate, err := commons.ParseTime(market.CreatedAt)
 		if err != nil {
-			createdDate, err := commons.ParseTime(market.CreatedAt)
-			if err != nil {
-				log.Fatalf("Unable to parse start date: \"%s\"/\"%s\"", market.StartDate, market.CreatedAt)
-				return
-			}
-			startDate = createdDate
+			return false, newPermanentError("unable to parse start date: \"%s\"/\"%s\"", market.StartDate, market.CreatedAt)
+		}
+		startDate = createdDate
+	}
+	for _, yes := range []bool{true, false} {
+		outputPath := path
+		if !yes {
+			outputPath = noPath
+		}
+		if commons.FileExists(outputPath) {
+			continue
 		}
-		yesID, err := getCLOBTokenID(market, true)
+		tokenID, err := getCLOBTokenID(market, yes)
 		if err != nil {
-			return
+			return false, newPermanentError("%v", err)
 		}
-		history, err := gamma.GetPriceHistory(yesID, startDate, historyFidelitySingle)
+		scheduler.throttle()
+		history, err := gamma.GetPriceHistory(tokenID, startDate, historyFidelitySingle)
 		if err != nil {
-			return
+			return false, err
 		}
 		output := "time,price\n"
 		for _, sample := range history.History {
 			timestamp := time.Unix(int64(sample.Time), 0).UTC()
 			output += fmt.Sprintf("%s,%g\n", commons.GetTimeString(timestamp), sample.Price)
 		}
-		commons.WriteFile(path, output)
-		log.Printf("Downloaded %d samples to %s", len(history.History), path)
+		commons.WriteFile(outputPath, output)
+		log.Printf("Downloaded %d samples to %s", len(history.History), outputPath)
 	}
+	return false, nil
 }
\ No newline at end of file
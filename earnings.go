@@ -32,13 +32,14 @@ func runEarningsSystem() {
 	go watchEDGAR(&wg)
 	go watchInvesting(&wg)
 	wg.Wait()
+	waitForAlerts()
 }
 
 func watchEDGAR(wg *sync.WaitGroup) {
 	companies := map[string]companyEDGARData{}
 	keepWatching := true
 	for keepWatching {
-		for _, company := range configuration.Earnings {
+		for _, company := range getConfiguration().Earnings {
 			getEDGARData(company, &companies)
 			keepWatching = false
 			for _, data := range companies {
@@ -93,7 +94,7 @@ func getEDGARData(company EarningsConfiguration, companies *map[string]companyED
 	}
 	if !data.triggered && body != data.body {
 		log.Printf("Detected a change in the filings of %s (CIK %s)", company.Symbol, company.CIK)
-		beep()
+		sendAlert(alertWarning, company.Symbol, "Detected a change in the filings of %s (CIK %s)", company.Symbol, company.CIK)
 		data.triggered = true
 	}
 	(*companies)[key] = data
@@ -101,7 +102,7 @@ func getEDGARData(company EarningsConfiguration, companies *map[string]companyED
 
 func watchInvesting(wg *sync.WaitGroup) {
 	data := []companyInvestingData{}
-	for _, company := range configuration.Earnings {
+	for _, company := range getConfiguration().Earnings {
 		investingData := companyInvestingData{
 			symbol: company.Symbol,
 			eps: "--",
@@ -170,7 +171,7 @@ func getInvestingData(data *[]companyInvestingData) {
 		investingData := &(*data)[i]
 		if !investingData.triggered && eps != investingData.eps {
 			log.Printf("Detected investing.com EPS for %s: %s", symbol, eps)
-			beep()
+			sendAlert(alertWarning, symbol, "Detected investing.com EPS for %s: %s", symbol, eps)
 			investingData.eps = eps
 			investingData.triggered = true
 		}
@@ -156,7 +156,7 @@ func loadFightDirectory(event string, directory string) ([]fightResult, []filter
 	paths := []string{}
 	for _, entry := range entries {
 		name := entry.Name()
-		if !entry.IsDir() && filepath.Ext(name) == ".csv" && !strings.Contains(name, "-x-") && !strings.Contains(name, "-y-") {
+		if !entry.IsDir() && filepath.Ext(name) == ".csv" && !strings.Contains(name, "-x-") && !strings.Contains(name, "-y-") && !strings.HasSuffix(name, noHistorySuffix) {
 			path := filepath.Join(directory, name)
 			stats, err := os.Stat(path)
 			if err != nil {
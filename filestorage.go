@@ -0,0 +1,454 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/encratite/gamma"
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	fileStorageExtension = ".bson"
+	fileStorageDayFormat = "2006-01-02"
+)
+
+type fileStorage struct {
+	eventMutex sync.Mutex
+	directory string
+	files map[string]*os.File
+	marketSlugs map[string]bool
+}
+
+func newFileStorage(directory string) *fileStorage {
+	directories := []string{
+		directory,
+		filepath.Join(directory, bookEventCollection),
+		filepath.Join(directory, priceChangeCollection),
+		filepath.Join(directory, lastTradePriceCollection),
+	}
+	for _, path := range directories {
+		err := os.MkdirAll(path, 0755)
+		if err != nil {
+			log.Fatalf("Failed to create storage directory %s: %v", path, err)
+		}
+	}
+	s := &fileStorage{
+		directory: directory,
+		files: map[string]*os.File{},
+		marketSlugs: nil,
+	}
+	return s
+}
+
+func (s *fileStorage) close() {
+	s.flushBuffer()
+}
+
+func (s *fileStorage) flushBuffer() {
+	for path := range s.files {
+		s.closeFile(path)
+	}
+}
+
+func (s *fileStorage) insertMarkets(markets []gamma.Market, assetIDs []string, eventSlugMap map[string]string) {
+	if s.marketSlugs == nil {
+		s.marketSlugs = map[string]bool{}
+		for _, market := range s.getMarkets() {
+			s.marketSlugs[market.Slug] = true
+		}
+	}
+	dbMarkets, dbVolume := getMarketDocuments(markets, assetIDs, eventSlugMap)
+	for _, market := range dbMarkets {
+		if s.marketSlugs[market.Slug] {
+			continue
+		}
+		s.appendDocument(s.getPath(marketCollection), market)
+		s.marketSlugs[market.Slug] = true
+	}
+	for _, volume := range dbVolume {
+		s.appendDocument(s.getPath(marketVolumeCollection), volume)
+	}
+}
+
+func (s *fileStorage) insertBookMessage(message gamma.BookMessage, subscription marketSubscription) {
+	switch message.EventType {
+	case gamma.BookEvent:
+		bookEvent, err := newBookEvent(message)
+		if err != nil {
+			return
+		}
+		s.appendDocument(s.getDayPath(bookEventCollection, bookEvent.ServerTime), bookEvent)
+	case gamma.PriceChangeEvent:
+		priceChanges, err := newPriceChanges(message)
+		if err != nil {
+			return
+		}
+		for _, priceChange := range priceChanges {
+			s.appendDocument(s.getDayPath(priceChangeCollection, priceChange.ServerTime), priceChange)
+		}
+	case gamma.LastTradePriceEvent:
+		lastTradePrice, err := newLastTradePrice(message, subscription)
+		if err != nil {
+			return
+		}
+		s.appendDocument(s.getDayPath(lastTradePriceCollection, lastTradePrice.ServerTime), lastTradePrice)
+	}
+}
+
+func (s *fileStorage) insertGap(gap *CollectionGap) {
+	gap.ID = bson.NewObjectID()
+	s.appendDocument(s.getPath(gapCollection), gap)
+}
+
+func (s *fileStorage) closeGap(gap *CollectionGap, reconnected time.Time) {
+	gap.Reconnected = &reconnected
+	path := s.getPath(gapCollection)
+	s.closeFile(path)
+	gaps := readBSONFile[CollectionGap](path)
+	for i := range gaps {
+		if gaps[i].ID == gap.ID {
+			gaps[i].Reconnected = &reconnected
+		}
+	}
+	writeBSONFile(path, gaps)
+}
+
+func (s *fileStorage) priceHistoryCheck(slug string, fidelity int) priceHistoryStatus {
+	path := s.getHistoryPath(slug, fidelity)
+	if !commons.FileExists(path) {
+		return getMissingHistoryStatus(false)
+	}
+	histories := readBSONFile[PriceHistoryBSON](path)
+	if len(histories) == 0 {
+		log.Printf("Failed to determine if price history exists: %s is empty", path)
+		return getMissingHistoryStatus(true)
+	}
+	return getPriceHistoryStatus(histories[0])
+}
+
+func (s *fileStorage) insertPriceHistory(history PriceHistoryBSON) {
+	writeBSONFile(s.getHistoryPath(history.Slug, history.Fidelity), []PriceHistoryBSON{history})
+}
+
+func (s *fileStorage) updatePriceHistory(history PriceHistoryBSON) {
+	path := s.getHistoryPath(history.Slug, history.Fidelity)
+	histories := readBSONFile[PriceHistoryBSON](path)
+	if len(histories) == 0 {
+		log.Printf("Warning: failed to update price history of %s: %s is missing or empty", history.Slug, path)
+		return
+	}
+	previous := histories[0]
+	history.StartDate = previous.StartDate
+	history.History = append(previous.History, history.History...)
+	history.NoHistory = append(previous.NoHistory, history.NoHistory...)
+	writeBSONFile(path, []PriceHistoryBSON{history})
+}
+
+func (s *fileStorage) getPriceHistoryData(fidelity int, closed *bool, negRisk *bool, minVolume *float64, tag *string) []PriceHistoryBSON {
+	historyData := []PriceHistoryBSON{}
+	s.readHistory(fidelity, func (history PriceHistoryBSON) {
+		if closed != nil && history.Closed != *closed {
+			return
+		}
+		if negRisk != nil && history.NegRisk != *negRisk {
+			return
+		}
+		if minVolume != nil && history.Volume < *minVolume {
+			return
+		}
+		if tag != nil && !commons.Contains(history.Tags, *tag) {
+			return
+		}
+		historyData = append(historyData, history)
+	})
+	return historyData
+}
+
+func (s *fileStorage) getTagsOnly(fidelity int) []PriceHistoryBSON {
+	historyData := []PriceHistoryBSON{}
+	s.readHistory(fidelity, func (history PriceHistoryBSON) {
+		tagsOnly := PriceHistoryBSON{
+			Slug: history.Slug,
+			Tags: history.Tags,
+		}
+		historyData = append(historyData, tagsOnly)
+	})
+	return historyData
+}
+
+func (s *fileStorage) readHistory(fidelity int, handler func (PriceHistoryBSON)) {
+	directory := filepath.Join(s.directory, getHistoryCollection(fidelity))
+	_, err := os.Stat(directory)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	for _, path := range getStorageFiles(directory) {
+		for _, history := range readBSONFile[PriceHistoryBSON](path) {
+			handler(history)
+		}
+	}
+}
+
+func (s *fileStorage) upsertEvent(event EventBSON) {
+	s.eventMutex.Lock()
+	defer s.eventMutex.Unlock()
+	path := s.getPath(eventCollection)
+	events := readBSONFile[EventBSON](path)
+	index := slices.IndexFunc(events, func (e EventBSON) bool {
+		return e.Slug == event.Slug
+	})
+	if index >= 0 {
+		events[index] = event
+	} else {
+		events = append(events, event)
+	}
+	writeBSONFile(path, events)
+}
+
+func (s *fileStorage) getEvents() []EventBSON {
+	s.eventMutex.Lock()
+	defer s.eventMutex.Unlock()
+	return readBSONFile[EventBSON](s.getPath(eventCollection))
+}
+
+func (s *fileStorage) getMarkets() []MarketBSON {
+	return readBSONFile[MarketBSON](s.getPath(marketCollection))
+}
+
+func (s *fileStorage) getBookEvents(assetID string) []BookEvent {
+	s.flushBuffer()
+	bookEvents := readCollection(s.directory, bookEventCollection, nil, nil, func (e BookEvent) time.Time {
+		return e.ServerTime
+	})
+	return filterByAssetID(bookEvents, assetID, func (e BookEvent) string {
+		return e.AssetID
+	})
+}
+
+func (s *fileStorage) getPriceChanges(assetID string) []PriceChangeBSON {
+	s.flushBuffer()
+	priceChanges := readCollection(s.directory, priceChangeCollection, nil, nil, func (c PriceChangeBSON) time.Time {
+		return c.ServerTime
+	})
+	return filterByAssetID(priceChanges, assetID, func (c PriceChangeBSON) string {
+		return c.AssetID
+	})
+}
+
+func (s *fileStorage) getBookEventsInRange(start, end time.Time) []BookEvent {
+	s.flushBuffer()
+	return readCollection(s.directory, bookEventCollection, &start, &end, func (e BookEvent) time.Time {
+		return e.ServerTime
+	})
+}
+
+func (s *fileStorage) getLastTradePricesInRange(start, end time.Time) []LastTradePrice {
+	s.flushBuffer()
+	return readCollection(s.directory, lastTradePriceCollection, &start, &end, func (p LastTradePrice) time.Time {
+		return p.ServerTime
+	})
+}
+
+func (s *fileStorage) getPriceChangesInRange(start, end time.Time) []PriceChangeBSON {
+	s.flushBuffer()
+	return readCollection(s.directory, priceChangeCollection, &start, &end, func (c PriceChangeBSON) time.Time {
+		return c.ServerTime
+	})
+}
+
+func (s *fileStorage) getGapsInRange(start, end time.Time) []CollectionGap {
+	gaps := []CollectionGap{}
+	for _, gap := range readBSONFile[CollectionGap](s.getPath(gapCollection)) {
+		if gap.Disconnected.Before(end) && (gap.Reconnected == nil || gap.Reconnected.After(start)) {
+			gaps = append(gaps, gap)
+		}
+	}
+	slices.SortStableFunc(gaps, func (a, b CollectionGap) int {
+		return a.Disconnected.Compare(b.Disconnected)
+	})
+	return gaps
+}
+
+func (s *fileStorage) appendDocument(path string, document any) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		log.Printf("Warning: failed to serialize document for %s: %v", path, err)
+		return
+	}
+	file, exists := s.files[path]
+	if !exists {
+		file, err = os.OpenFile(path, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0644)
+		if err != nil {
+			log.Printf("Warning: failed to open %s: %v", path, err)
+			return
+		}
+		s.files[path] = file
+	}
+	_, err = file.Write(data)
+	if err != nil {
+		log.Printf("Warning: failed to write to %s: %v", path, err)
+	}
+}
+
+func (s *fileStorage) closeFile(path string) {
+	file, exists := s.files[path]
+	if !exists {
+		return
+	}
+	err := file.Close()
+	if err != nil {
+		log.Printf("Warning: failed to close %s: %v", path, err)
+	}
+	delete(s.files, path)
+}
+
+func (s *fileStorage) getPath(collection string) string {
+	return filepath.Join(s.directory, collection + fileStorageExtension)
+}
+
+func (s *fileStorage) getDayPath(collection string, timestamp time.Time) string {
+	name := timestamp.UTC().Format(fileStorageDayFormat) + fileStorageExtension
+	return filepath.Join(s.directory, collection, name)
+}
+
+func (s *fileStorage) getHistoryPath(slug string, fidelity int) string {
+	directory := filepath.Join(s.directory, getHistoryCollection(fidelity))
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		log.Fatalf("Failed to create storage directory %s: %v", directory, err)
+	}
+	return filepath.Join(directory, slug + fileStorageExtension)
+}
+
+func readCollection[T any](directory, collection string, start, end *time.Time, getTime func (T) time.Time) []T {
+	documents := []T{}
+	for _, path := range getStorageFiles(filepath.Join(directory, collection)) {
+		if start != nil {
+			name := strings.TrimSuffix(filepath.Base(path), fileStorageExtension)
+			day, err := time.Parse(fileStorageDayFormat, name)
+			if err != nil || !day.AddDate(0, 0, 1).After(*start) || !day.Before(*end) {
+				continue
+			}
+		}
+		for _, document := range readBSONFile[T](path) {
+			timestamp := getTime(document)
+			if start == nil || (!timestamp.Before(*start) && timestamp.Before(*end)) {
+				documents = append(documents, document)
+			}
+		}
+	}
+	slices.SortStableFunc(documents, func (a, b T) int {
+		return getTime(a).Compare(getTime(b))
+	})
+	return documents
+}
+
+func filterByAssetID[T any](documents []T, assetID string, getAssetID func (T) string) []T {
+	output := []T{}
+	for _, document := range documents {
+		if getAssetID(document) == assetID {
+			output = append(output, document)
+		}
+	}
+	return output
+}
+
+func getStorageFiles(directory string) []string {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		log.Fatalf("Failed to read storage directory %s: %v", directory, err)
+	}
+	paths := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), fileStorageExtension) {
+			paths = append(paths, filepath.Join(directory, entry.Name()))
+		}
+	}
+	return paths
+}
+
+func readBSONFile[T any](path string) []T {
+	documents := []T{}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return documents
+	} else if err != nil {
+		log.Fatalf("Failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for {
+		data, err := readBSONDocument(reader)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			log.Printf("Warning: ignoring the remainder of %s: %v", path, err)
+			break
+		}
+		var document T
+		err = bson.Unmarshal(data, &document)
+		if err != nil {
+			log.Fatalf("Failed to deserialize document in %s: %v", path, err)
+		}
+		documents = append(documents, document)
+	}
+	return documents
+}
+
+func writeBSONFile[T any](path string, documents []T) {
+	temporaryPath := path + ".tmp"
+	file, err := os.Create(temporaryPath)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", temporaryPath, err)
+	}
+	writer := bufio.NewWriter(file)
+	for _, document := range documents {
+		data, err := bson.Marshal(document)
+		if err != nil {
+			log.Fatalf("Failed to serialize document for %s: %v", path, err)
+		}
+		writer.Write(data)
+	}
+	err = writer.Flush()
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Fatalf("Failed to write %s: %v", temporaryPath, err)
+	}
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		log.Fatalf("Failed to replace %s: %v", path, err)
+	}
+}
+
+func readBSONDocument(reader *bufio.Reader) (bson.Raw, error) {
+	header, err := reader.Peek(4)
+	if err == io.EOF && len(header) == 0 {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, fmt.Errorf("truncated document header")
+	}
+	length := int(binary.LittleEndian.Uint32(header))
+	if length < 5 {
+		return nil, fmt.Errorf("invalid document length %d", length)
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(reader, data)
+	if err != nil {
+		return nil, fmt.Errorf("truncated document")
+	}
+	return data, nil
+}
\ No newline at end of file
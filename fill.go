@@ -0,0 +1,228 @@
+package main
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+const (
+	fillModelSynthetic = "synthetic"
+	fillModelBook = "book"
+	bookFillMaxAge = 24
+)
+
+type fillModel interface {
+	getFill(backtest *backtestData, slug string, side backtestPositionSide, size float64, buy bool) (float64, float64, bool)
+	close()
+}
+
+type syntheticFillModel struct{}
+
+type bookFillModel struct {
+	fallback syntheticFillModel
+	database storage
+	assetIDs map[string]string
+	mutex sync.Mutex
+	histories map[string]*bookHistory
+}
+
+type bookHistory struct {
+	snapshots []BookEvent
+	changes []PriceChangeBSON
+}
+
+type bookLevel struct {
+	price float64
+	size float64
+}
+
+func newFillModel(name string) fillModel {
+	switch name {
+	case fillModelSynthetic:
+		return &syntheticFillModel{}
+	case fillModelBook:
+		return newBookFillModel()
+	}
+	log.Fatalf("Unknown fill model: %s", name)
+	return nil
+}
+
+func (m *syntheticFillModel) getFill(backtest *backtestData, slug string, side backtestPositionSide, size float64, buy bool) (float64, float64, bool) {
+	_, exists := backtest.getPriceErr(slug)
+	if !exists {
+		return 0.0, 0.0, false
+	}
+	bid, ask := backtest.getBidAsk(slug, side)
+	if buy {
+		return ask, size, true
+	} else {
+		return bid, size, true
+	}
+}
+
+func (m *syntheticFillModel) close() {
+}
+
+func newBookFillModel() *bookFillModel {
+	database := newStorage()
+	assetIDs := map[string]string{}
+	for _, market := range database.getMarkets() {
+		assetIDs[market.Slug] = market.AssetID
+	}
+	log.Printf("Loaded asset IDs of %d recorded markets for the order book fill model", len(assetIDs))
+	return &bookFillModel{
+		fallback: syntheticFillModel{},
+		database: database,
+		assetIDs: assetIDs,
+		histories: map[string]*bookHistory{},
+	}
+}
+
+func (m *bookFillModel) getFill(backtest *backtestData, slug string, side backtestPositionSide, size float64, buy bool) (float64, float64, bool) {
+	history := m.getHistory(slug)
+	if history == nil {
+		return m.fallback.getFill(backtest, slug, side, size, buy)
+	}
+	bids, asks, exists := history.getBook(backtest.now)
+	if !exists {
+		return m.fallback.getFill(backtest, slug, side, size, buy)
+	}
+	var levels []bookLevel
+	if (side == sideYes) == buy {
+		levels = asks
+	} else {
+		levels = bids
+	}
+	if len(levels) == 0 {
+		return m.fallback.getFill(backtest, slug, side, size, buy)
+	}
+	remaining := size
+	cost := 0.0
+	for _, level := range levels {
+		price := convertPrice(level.price, side)
+		quantity := min(remaining, level.size)
+		cost += quantity * price
+		remaining -= quantity
+		if remaining <= 0.0 {
+			break
+		}
+	}
+	filled := size - remaining
+	if filled <= 0.0 {
+		return 0.0, 0.0, false
+	}
+	return cost / filled, filled, true
+}
+
+func (m *bookFillModel) close() {
+	m.database.close()
+}
+
+func (m *bookFillModel) getHistory(slug string) *bookHistory {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	history, exists := m.histories[slug]
+	if exists {
+		return history
+	}
+	assetID, exists := m.assetIDs[slug]
+	if exists {
+		history = &bookHistory{
+			snapshots: m.database.getBookEvents(assetID),
+			changes: m.database.getPriceChanges(assetID),
+		}
+		if len(history.snapshots) == 0 {
+			history = nil
+		}
+	}
+	m.histories[slug] = history
+	return history
+}
+
+func (h *bookHistory) getBook(timestamp time.Time) ([]bookLevel, []bookLevel, bool) {
+	index := sort.Search(len(h.snapshots), func (i int) bool {
+		return h.snapshots[i].ServerTime.After(timestamp)
+	}) - 1
+	if index < 0 {
+		return nil, nil, false
+	}
+	snapshot := h.snapshots[index]
+	lastUpdate := snapshot.ServerTime
+	bids := map[float64]float64{}
+	asks := map[float64]float64{}
+	for _, level := range snapshot.Bids {
+		bids[decimal128ToFloat(level.Price)] = decimal128ToFloat(level.Size)
+	}
+	for _, level := range snapshot.Asks {
+		asks[decimal128ToFloat(level.Price)] = decimal128ToFloat(level.Size)
+	}
+	start := sort.Search(len(h.changes), func (i int) bool {
+		return h.changes[i].ServerTime.After(snapshot.ServerTime)
+	})
+	for _, change := range h.changes[start:] {
+		if change.ServerTime.After(timestamp) {
+			break
+		}
+		price := decimal128ToFloat(change.Price)
+		size := decimal128ToFloat(change.Size)
+		side, otherSide := bids, asks
+		if !change.Buy {
+			side, otherSide = asks, bids
+		}
+		if size > 0.0 {
+			side[price] = size
+			for otherPrice := range otherSide {
+				crossed := (change.Buy && otherPrice <= price) || (!change.Buy && otherPrice >= price)
+				if crossed {
+					delete(otherSide, otherPrice)
+				}
+			}
+		} else {
+			delete(side, price)
+			delete(otherSide, price)
+		}
+		lastUpdate = change.ServerTime
+	}
+	maxAge := time.Duration(bookFillMaxAge) * time.Hour
+	if timestamp.Sub(lastUpdate) > maxAge {
+		return nil, nil, false
+	}
+	bidLevels := getBookLevels(bids, true)
+	askLevels := getBookLevels(asks, false)
+	return bidLevels, askLevels, true
+}
+
+func getBookLevels(book map[float64]float64, descending bool) []bookLevel {
+	levels := []bookLevel{}
+	for price, size := range book {
+		level := bookLevel{
+			price: price,
+			size: size,
+		}
+		levels = append(levels, level)
+	}
+	slices.SortFunc(levels, func (a, b bookLevel) int {
+		if descending {
+			return cmp.Compare(b.price, a.price)
+		} else {
+			return cmp.Compare(a.price, b.price)
+		}
+	})
+	return levels
+}
+
+func decimal128ToFloat(value bson.Decimal128) float64 {
+	output, err := strconv.ParseFloat(value.String(), 64)
+	if err != nil {
+		log.Printf("Warning: failed to convert decimal %s: %v", value, err)
+		return 0.0
+	}
+	return output
+}
\ No newline at end of file
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/encratite/commons"
@@ -17,86 +19,257 @@ const (
 	historyFidelity = 60
 )
 
+type historyEvents struct {
+	mutex sync.Mutex
+	updated map[string]bool
+}
+
 func updateHistory() {
 	loadConfiguration()
-	database := newDatabaseClient()
+	database := newStorage()
 	defer database.close()
-	for offset := 0; offset < historyMaxOffset; offset += historyPageLimit {
+	scheduler := newDownloadScheduler()
+	config := getConfiguration().History
+	tags := config.Tags
+	if len(tags) == 0 {
+		tags = []string{""}
+	}
+	events := &historyEvents{
+		updated: map[string]bool{},
+	}
+	cursor := scheduler.loadCursor()
+	skipping := true
+	if !commons.Contains(tags, cursor.Tag) {
+		cursor = downloadCursorState{}
+		skipping = false
+	}
+	for _, tag := range tags {
+		if skipping && tag != cursor.Tag {
+			continue
+		}
+		skipping = false
+		tagID, err := getHistoryTagID(scheduler, tag)
+		if err != nil {
+			scheduler.fail(tag, err)
+			continue
+		}
+		updateTagHistory(database, scheduler, events, tag, tagID, cursor.Offset)
+		cursor.Offset = 0
+	}
+	scheduler.clearCursor()
+	scheduler.printSummary()
+}
+
+func getHistoryTagID(scheduler *downloadScheduler, slug string) (*int, error) {
+	if slug == "" {
+		return nil, nil
+	}
+	var tagID int
+	err := scheduler.request(slug, func () error {
+		tag, err := gamma.GetTag(slug)
+		if err != nil {
+			return err
+		}
+		tagID, err = strconv.Atoi(tag.ID)
+		if err != nil {
+			return newPermanentError("invalid tag ID \"%s\"", tag.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tagID, nil
+}
+
+func updateTagHistory(database storage, scheduler *downloadScheduler, events *historyEvents, tag string, tagID *int, start int) {
+	config := getConfiguration().History
+	startDate := config.Start.Format(time.DateOnly)
+	for offset := start; offset < *config.MaxMarkets; offset += historyPageLimit {
 		log.Printf("Downloading markets at offset %d", offset)
-		markets, err := gamma.GetMarkets(offset, historyPageLimit, historyOrder, historyStartDateMin, nil)
+		var markets []gamma.Market
+		key := fmt.Sprintf("markets at offset %d", offset)
+		err := scheduler.request(key, func () error {
+			var err error
+			markets, err = gamma.GetMarkets(offset, historyPageLimit, *config.Order, startDate, tagID)
+			return err
+		})
 		if err != nil {
-			return
+			scheduler.printSummary()
+			log.Fatalf("Aborting download, run -history again to resume at offset %d", offset)
 		}
 		if len(markets) == 0 {
 			break
 		}
 		for _, market := range markets {
-			slug := market.Slug
-			exists, closed := database.priceHistoryCheck(slug)
-			if exists {
-				if closed {
-					log.Printf("Skipping \"%s\"", slug)
-					continue
-				} else {
-					log.Printf("Updating \"%s\"", slug)
-					database.deletePriceHistory(slug)
-				}
-			}
-			if len(market.Events) == 0 {
-				continue
-			}
-			event := market.Events[0]
-			eventID, err := strconv.Atoi(event.ID)
-			if err != nil {
-				continue
-			}
-			eventTags, err := gamma.GetEventTags(eventID)
-			if err != nil {
-				continue
-			}
-			tagSlugs := []string{}
-			for _, eventTag := range eventTags {
-				tagSlugs = append(tagSlugs, eventTag.Slug)
-			}
-			startDate, err := commons.ParseTime(market.StartDate)
-			if err != nil {
-				continue
-			}
-			var endDatePointer *time.Time = nil
-			endDate, endDateErr := commons.ParseTime(market.EndDate)
-			if endDateErr != nil {
-				endDatePointer = &endDate
-			}
-			yesID, err := getCLOBTokenID(market, true)
-			if err != nil {
-				continue
-			}
-			history, err := gamma.GetPriceHistory(yesID, startDate, historyFidelity)
-			dbSamples := []PriceHistorySampleBSON{}
-			for _, s := range history.History {
-				timestamp := time.Unix(int64(s.Time), 0).UTC()
-				sample := PriceHistorySampleBSON{
-					Timestamp: timestamp,
-					Price: s.Price,
-				}
-				dbSamples = append(dbSamples, sample)
-			}
-			outcome := getMarketOutcome(market)
-			dbHistory := PriceHistoryBSON{
-				Slug: slug,
-				NegRisk: market.NegRisk,
-				Closed: market.Closed,
-				StartDate: startDate,
-				EndDate: endDatePointer,
-				Volume: market.VolumeNum,
-				Outcome: outcome,
-				Tags: tagSlugs,
-				History: dbSamples,
-			}
+			scheduler.submit(market.Slug, func () (bool, error) {
+				return updateMarketHistory(database, scheduler, events, market)
+			})
+		}
+		scheduler.wait()
+		cursor := downloadCursorState{
+			Tag: tag,
+			Offset: offset + historyPageLimit,
+		}
+		scheduler.saveCursor(cursor)
+	}
+}
+
+func updateMarketHistory(database storage, scheduler *downloadScheduler, events *historyEvents, market gamma.Market) (bool, error) {
+	slug := market.Slug
+	fidelities := []int{}
+	for _, fidelity := range getConfiguration().History.Fidelities {
+		status := database.priceHistoryCheck(slug, fidelity)
+		if !status.exists || !status.complete {
+			fidelities = append(fidelities, fidelity)
+		}
+	}
+	if len(fidelities) == 0 {
+		log.Printf("Skipping \"%s\"", slug)
+		return true, nil
+	}
+	if len(market.Events) == 0 {
+		return true, nil
+	}
+	startDate, err := commons.ParseTime(market.StartDate)
+	if err != nil {
+		return false, newPermanentError("invalid start date \"%s\"", market.StartDate)
+	}
+	end := getConfiguration().History.End
+	if end != nil && !startDate.Before(end.Time) {
+		return true, nil
+	}
+	event := market.Events[0]
+	eventID, err := strconv.Atoi(event.ID)
+	if err != nil {
+		return false, newPermanentError("invalid event ID \"%s\"", event.ID)
+	}
+	scheduler.throttle()
+	eventTags, err := gamma.GetEventTags(eventID)
+	if err != nil {
+		return false, err
+	}
+	tagSlugs := []string{}
+	for _, eventTag := range eventTags {
+		tagSlugs = append(tagSlugs, eventTag.Slug)
+	}
+	err = events.update(database, scheduler, event.Slug, tagSlugs)
+	if err != nil {
+		return false, err
+	}
+	var endDatePointer *time.Time = nil
+	endDate, endDateErr := commons.ParseTime(market.EndDate)
+	if endDateErr == nil {
+		endDatePointer = &endDate
+	}
+	yesID, err := getCLOBTokenID(market, true)
+	if err != nil {
+		return false, newPermanentError("%v", err)
+	}
+	noID, err := getCLOBTokenID(market, false)
+	if err != nil {
+		return false, newPermanentError("%v", err)
+	}
+	for _, fidelity := range fidelities {
+		status := database.priceHistoryCheck(slug, fidelity)
+		dbSamples, err := getHistorySamples(scheduler, yesID, getHistoryStart(startDate, status.lastTimestamp), status.lastTimestamp, end, fidelity)
+		if err != nil {
+			return false, err
+		}
+		noSamples, err := getHistorySamples(scheduler, noID, getHistoryStart(startDate, status.lastNoTimestamp), status.lastNoTimestamp, end, fidelity)
+		if err != nil {
+			return false, err
+		}
+		outcome := getMarketOutcome(market)
+		dbHistory := PriceHistoryBSON{
+			Slug: slug,
+			EventID: event.ID,
+			EventSlug: event.Slug,
+			NegRisk: market.NegRisk,
+			Closed: market.Closed,
+			Fidelity: fidelity,
+			StartDate: startDate,
+			EndDate: endDatePointer,
+			Volume: market.VolumeNum,
+			Outcome: outcome,
+			Tags: tagSlugs,
+			History: dbSamples,
+			NoHistory: noSamples,
+			NoFetched: true,
+		}
+		if status.exists {
+			database.updatePriceHistory(dbHistory)
+			log.Printf("Appended %d records to the price history of \"%s\" (fidelity %d)", len(dbSamples), slug, fidelity)
+		} else {
 			database.insertPriceHistory(dbHistory)
-			log.Printf("Downloaded price history for \"%s\" (%d records)", slug, len(dbSamples))
+			log.Printf("Downloaded price history for \"%s\" (%d records, fidelity %d)", slug, len(dbSamples), fidelity)
+		}
+	}
+	return false, nil
+}
+
+func (e *historyEvents) update(database storage, scheduler *downloadScheduler, slug string, tags []string) error {
+	e.mutex.Lock()
+	updated := e.updated[slug]
+	e.mutex.Unlock()
+	if updated {
+		return nil
+	}
+	scheduler.throttle()
+	event, err := gamma.GetEventBySlug(slug)
+	if err != nil {
+		return err
+	}
+	markets := []string{}
+	for _, market := range event.Markets {
+		markets = append(markets, market.Slug)
+	}
+	dbEvent := EventBSON{
+		EventID: event.ID,
+		Slug: event.Slug,
+		Title: event.Title,
+		NegRisk: event.NegRisk,
+		Closed: event.Closed,
+		Tags: tags,
+		Markets: markets,
+		Updated: time.Now(),
+	}
+	database.upsertEvent(dbEvent)
+	e.mutex.Lock()
+	e.updated[slug] = true
+	e.mutex.Unlock()
+	return nil
+}
+
+func getHistoryStart(startDate time.Time, lastTimestamp *time.Time) time.Time {
+	if lastTimestamp == nil {
+		return startDate
+	}
+	return lastTimestamp.Add(time.Second)
+}
+
+func getHistorySamples(scheduler *downloadScheduler, tokenID string, start time.Time, lastTimestamp *time.Time, end *commons.SerializableDate, fidelity int) ([]PriceHistorySampleBSON, error) {
+	scheduler.throttle()
+	history, err := gamma.GetPriceHistory(tokenID, start, fidelity)
+	if err != nil {
+		return nil, err
+	}
+	samples := []PriceHistorySampleBSON{}
+	for _, s := range history.History {
+		timestamp := time.Unix(int64(s.Time), 0).UTC()
+		if lastTimestamp != nil && !timestamp.After(*lastTimestamp) {
+			continue
+		}
+		if end != nil && !timestamp.Before(end.Time) {
+			continue
+		}
+		sample := PriceHistorySampleBSON{
+			Timestamp: timestamp,
+			Price: s.Price,
 		}
+		samples = append(samples, sample)
 	}
+	return samples, nil
 }
 
 func getMarketOutcome(market gamma.Market) *bool {
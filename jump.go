@@ -3,30 +3,69 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"os/signal"
 	"time"
 
+	"github.com/emirpasic/gods/maps/treemap"
 	"github.com/encratite/commons"
 	"github.com/encratite/gamma"
 	"github.com/fatih/color"
 	"github.com/gammazero/deque"
+	"github.com/polymarket/go-order-utils/pkg/model"
 	"github.com/shopspring/decimal"
 )
 
+const (
+	jumpEntryTimeout = 60
+)
+
 type jumpTradingSystem struct {
 	threshold1 decimal.Decimal
 	threshold2 decimal.Decimal
 	threshold3 decimal.Decimal
+	spreadLimit decimal.Decimal
 	includeTags []string
 	excludeTags []string
+	autoTrade bool
+	size decimal.Decimal
+	holdingTime time.Duration
+	entryTimeout time.Duration
+	stopLoss bool
 	subscriptions map[string]jumpSubscription
+	orders *orderManager
+	paper *paperBroker
+	state *stateStore
+	restored *JumpSystemState
+	watcher *configurationWatcher
+	loop *eventLoop
+	resubscribe bool
 }
 
 type jumpSubscription struct {
 	market gamma.Market
 	yesID string
+	noID string
 	prices deque.Deque[jumpPriceEvent]
 	triggered bool
 	spread *decimal.Decimal
+	bestBid *decimal.Decimal
+	bestAsk *decimal.Decimal
+	bids *treemap.Map
+	asks *treemap.Map
+	position *jumpPosition
+	integrity bookIntegrity
+}
+
+type jumpPosition struct {
+	timestamp time.Time
+	size decimal.Decimal
+	limit decimal.Decimal
+	open bool
+	exiting bool
+	closed bool
+	canceling bool
+	orderID string
 }
 
 type jumpPriceEvent struct {
@@ -36,68 +75,160 @@ type jumpPriceEvent struct {
 
 func runJumpSystem() {
 	loadConfiguration()
-	config := configuration.Jump
+	validateJumpConfiguration()
+	live := *getConfiguration().Jump.AutoTrade && *getConfiguration().Jump.Live
+	var executor orderExecutor = newCLOBExecutor(live)
+	var paper *paperBroker
+	if *getConfiguration().Jump.AutoTrade && *getConfiguration().Jump.Paper {
+		paper = newPaperBroker()
+		executor = paper
+	}
+	orders := newOrderManager(executor)
+	if *getConfiguration().Jump.AutoTrade {
+		orders.enableRiskManagement(live)
+	}
+	orders.run()
+	system := newJumpTradingSystem(orders, newEventLoop(false))
+	system.paper = paper
+	system.state = newStateStore(getConfiguration().Jump.State)
+	var restored JumpSystemState
+	if system.state.load(&restored) {
+		system.restored = &restored
+		system.orders.restoreRisk(restored.Risk)
+	}
+	system.watcher = newConfigurationWatcher(func (c *Configuration) error {
+		return c.Jump.validate()
+	}, func (next *Configuration) {
+		system.loop.post(func () {
+			system.onReload(next)
+		})
+	})
+	if live {
+		log.Printf("Warning: system is LIVE and has permission to post orders")
+	}
+	system.run()
+}
+
+func newJumpTradingSystem(orders *orderManager, loop *eventLoop) *jumpTradingSystem {
 	system := jumpTradingSystem{
-		threshold1: config.Threshold1.Decimal,
-		threshold2: config.Threshold2.Decimal,
-		threshold3: config.Threshold3.Decimal,
-		includeTags: config.IncludeTags,
-		excludeTags: config.ExcludeTags,
 		subscriptions: map[string]jumpSubscription{},
+		orders: orders,
+		paper: nil,
+		state: nil,
+		restored: nil,
+		watcher: nil,
+		loop: loop,
+		resubscribe: false,
 	}
-	system.run()
+	orders.dispatch = loop.post
+	system.applyConfiguration(getConfiguration().Jump)
+	return &system
 }
 
-func (s *jumpTradingSystem) run() {
-	sleep := func () {
-		time.Sleep(time.Duration(reconnectDelay) * time.Second)
+func (s *jumpTradingSystem) applyConfiguration(config JumpConfiguration) {
+	s.threshold1 = config.Threshold1.Decimal
+	s.threshold2 = config.Threshold2.Decimal
+	s.threshold3 = config.Threshold3.Decimal
+	s.spreadLimit = config.SpreadLimit.Decimal
+	s.includeTags = config.IncludeTags
+	s.excludeTags = config.ExcludeTags
+	s.autoTrade = *config.AutoTrade
+	if s.autoTrade {
+		s.size = config.Size.Decimal
+		s.holdingTime = time.Duration(*config.HoldingTime) * time.Hour
+		s.entryTimeout = time.Duration(*config.EntryTimeout) * time.Second
+		s.stopLoss = *config.StopLoss
 	}
+}
+
+func (s *jumpTradingSystem) run() {
+	backoff := newReconnectBackoff()
+	s.interrupt()
+	s.loop.startTimer(s.onTimer)
 	for {
-		markets := s.getMarkets()
+		backoff.connect()
+		var includeTags, excludeTags []string
+		s.loop.invoke(func () {
+			includeTags = s.includeTags
+			excludeTags = s.excludeTags
+		})
+		markets := getJumpMarkets(includeTags, excludeTags)
 		if markets == nil {
-			sleep()
+			backoff.wait()
 			continue
 		}
 		assetIDs := []string{}
-		for _, market := range markets {
-			yesID, err := getCLOBTokenID(market, true)
-			if err != nil {
-				continue
-			}
-			s.subscriptions[market.ConditionID] = jumpSubscription{
-				market: market,
-				yesID: yesID,
-				prices: deque.Deque[jumpPriceEvent]{},
-				triggered: false,
-				spread: nil,
-			}
-			assetIDs = append(assetIDs, yesID)
-		}
+		s.loop.invoke(func () {
+			assetIDs = s.updateSubscriptions(markets)
+		})
 		log.Printf("Subscribed to %d markets", len(assetIDs))
 		err := gamma.SubscribeToMarkets(assetIDs, s.onBookMessage)
 		if err != nil {
 			log.Printf("Subscription error: %v", err)
 		}
-		sleep()
+		backoff.wait()
+	}
+}
+
+func (s *jumpTradingSystem) updateSubscriptions(markets []gamma.Market) []string {
+	assetIDs := []string{}
+	for _, market := range markets {
+		yesID, err := getCLOBTokenID(market, true)
+		if err != nil {
+			continue
+		}
+		noID, err := getCLOBTokenID(market, false)
+		if err != nil {
+			continue
+		}
+		subscription := jumpSubscription{
+			market: market,
+			yesID: yesID,
+			noID: noID,
+			prices: deque.Deque[jumpPriceEvent]{},
+			triggered: false,
+			spread: nil,
+			bestBid: nil,
+			bestAsk: nil,
+			bids: treemap.NewWith(decimalComparator),
+			asks: treemap.NewWith(decimalComparator),
+			position: nil,
+			integrity: bookIntegrity{},
+		}
+		previous, exists := s.subscriptions[market.ConditionID]
+		if exists {
+			subscription.prices = previous.prices
+			subscription.triggered = previous.triggered
+			subscription.position = previous.position
+		} else {
+			s.restoreSubscription(market.ConditionID, &subscription)
+		}
+		s.subscriptions[market.ConditionID] = subscription
+		if s.paper != nil {
+			s.paper.registerComplement(noID, yesID)
+		}
+		assetIDs = append(assetIDs, yesID)
 	}
+	s.saveState(true)
+	return assetIDs
 }
 
-func (s *jumpTradingSystem) getMarkets() []gamma.Market {
+func getJumpMarkets(includeTags, excludeTags []string) []gamma.Market {
 	markets := []gamma.Market{}
-	if len(s.includeTags) > 0 {
-		for _, tagSlug := range s.includeTags {
-			err := s.addMarkets(&tagSlug, &markets)
+	if len(includeTags) > 0 {
+		for _, tagSlug := range includeTags {
+			err := addJumpMarkets(&tagSlug, excludeTags, &markets)
 			if err != nil {
 				break
 			}
 		}
 	} else {
-		s.addMarkets(nil, &markets)
+		addJumpMarkets(nil, excludeTags, &markets)
 	}
 	return markets
 }
 
-func (s *jumpTradingSystem) addMarkets(tagSlug *string, markets *[]gamma.Market) error {
+func addJumpMarkets(tagSlug *string, excludeTags []string, markets *[]gamma.Market) error {
 	const negRisk = false
 	events, err := gamma.GetEvents(tagSlug)
 	if err != nil {
@@ -109,7 +240,7 @@ func (s *jumpTradingSystem) addMarkets(tagSlug *string, markets *[]gamma.Market)
 		}
 		include := true
 		for _, tag := range event.Tags {
-			if commons.Contains(s.excludeTags, tag.Slug) {
+			if commons.Contains(excludeTags, tag.Slug) {
 				include = false
 				break
 			}
@@ -126,25 +257,103 @@ func (s *jumpTradingSystem) addMarkets(tagSlug *string, markets *[]gamma.Market)
 }
 
 func (s *jumpTradingSystem) onBookMessage(message gamma.BookMessage) bool {
+	result := true
+	s.loop.invoke(func () {
+		result = s.processBookMessage(message)
+	})
+	return result
+}
+
+func (s *jumpTradingSystem) processBookMessage(message gamma.BookMessage) bool {
+	if s.resubscribe {
+		s.resubscribe = false
+		return false
+	}
+	s.updateSubscription(message)
+	return true
+}
+
+func (s *jumpTradingSystem) updateSubscription(message gamma.BookMessage) {
 	key := message.Market
 	subscription, exists := s.subscriptions[key]
 	if !exists {
 		log.Printf("Warning: received a message for an unknown subscription for market %s", message.Market)
-		return true
+		return
 	}
 	switch message.EventType {
+	case gamma.BookEvent:
+		putPriceLevels(subscription.bids, message.Bids)
+		putPriceLevels(subscription.asks, message.Asks)
+		subscription.integrity.onSnapshot(subscription.market.Slug)
 	case gamma.PriceChangeEvent:
 		s.onPriceChange(message, &subscription)
 	case gamma.LastTradePriceEvent:
 		s.onLastTradePrice(message, &subscription)
 	}
+	if s.paper != nil {
+		s.paper.update(subscription.yesID, subscription.bids, subscription.asks, message)
+	}
+	if subscription.position != nil {
+		if subscription.position.closed {
+			subscription.position = nil
+		} else {
+			s.checkExit(&subscription)
+		}
+	}
+	subscription.integrity.checkCrossed(subscription.market.Slug, subscription.bids, subscription.asks)
+	if subscription.integrity.stale && !s.loop.synchronous && shouldResync(&subscription.integrity, subscription.market.Slug) {
+		requestBookSnapshot(key, subscription.yesID, s.loop, s.updateSubscription)
+	}
 	s.subscriptions[key] = subscription
-	return true
+	s.saveState(false)
+}
+
+func (s *jumpTradingSystem) interrupt() {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		log.Println("Received interrupt signal, saving state")
+		s.loop.invoke(func () {
+			s.saveState(true)
+		})
+		os.Exit(0)
+	}()
+}
+
+func (s *jumpTradingSystem) onTimer() {
+	for key, subscription := range s.subscriptions {
+		if subscription.position == nil {
+			continue
+		}
+		if subscription.position.closed {
+			subscription.position = nil
+			s.subscriptions[key] = subscription
+		} else {
+			s.checkEntryTimeout(&subscription)
+			s.checkExit(&subscription)
+		}
+	}
+	s.saveState(false)
+}
+
+func (s *jumpTradingSystem) execute(action func ()) {
+	if s.loop.synchronous || s.paper != nil {
+		action()
+	} else {
+		go action()
+	}
+}
+
+func (s *jumpTradingSystem) saveState(force bool) {
+	s.state.save(s.getState, force)
 }
 
 func (s *jumpTradingSystem) onPriceChange(message gamma.BookMessage, subscription *jumpSubscription) {
 	for _, change := range message.PriceChanges {
 		if change.AssetID == subscription.yesID {
+			updateOrderBook(subscription.bids, subscription.asks, change)
+			subscription.integrity.checkPriceChange(subscription.market.Slug, subscription.bids, subscription.asks, change)
 			bestAsk, err := decimal.NewFromString(change.BestAsk)
 			if err != nil {
 				log.Printf("Failed to parse best ask: %s", change.BestAsk)
@@ -157,6 +366,8 @@ func (s *jumpTradingSystem) onPriceChange(message gamma.BookMessage, subscriptio
 			}
 			spread := bestAsk.Sub(bestBid)
 			subscription.spread = &spread
+			subscription.bestBid = &bestBid
+			subscription.bestAsk = &bestAsk
 		}
 	}
 }
@@ -175,20 +386,166 @@ func (s *jumpTradingSystem) onLastTradePrice(message gamma.BookMessage, subscrip
 		if !subscription.triggered {
 			log.Printf("Triggered: %s", green(message))
 			subscription.triggered = true
-			beep()
+			sendAlert(alertWarning, subscription.market.Slug, "Jump triggered: %s", message)
 		} else {
 			log.Printf("In range: %s", message)
 		}
+		if s.autoTrade && subscription.position == nil {
+			s.openPosition(subscription)
+		}
+	}
+}
+
+func (s *jumpTradingSystem) openPosition(subscription *jumpSubscription) {
+	slug := subscription.market.Slug
+	if subscription.integrity.stale {
+		log.Printf("Not buying NO for %s, the order book is stale", slug)
+		return
+	}
+	if subscription.spread == nil || subscription.bestBid == nil {
+		log.Printf("Not buying NO for %s, no spread data available yet", slug)
+		return
+	}
+	if subscription.spread.GreaterThan(s.spreadLimit) {
+		log.Printf("Not buying NO for %s, spread %s exceeds limit %s", slug, subscription.spread, s.spreadLimit)
+		return
+	}
+	limit := decimal.NewFromInt(1).Sub(*subscription.bestBid)
+	log.Printf("Buying %s NO contracts of %s at %s", s.size, slug, limit)
+	position := &jumpPosition{
+		timestamp: getTime(),
+		size: s.size,
+		limit: limit,
+		open: false,
+		exiting: false,
+		closed: false,
+		canceling: false,
+		orderID: "",
+	}
+	subscription.position = position
+	tokenID := subscription.noID
+	size := s.size
+	negRisk := subscription.market.NegRisk
+	onUpdate := s.getEntryCallback(position)
+	s.execute(func () {
+		orderID, err := s.orders.submit(slug, tokenID, model.BUY, size, limit, negRisk, 0, onUpdate)
+		s.loop.post(func () {
+			if err != nil {
+				log.Printf("Failed to execute order: %v", err)
+				position.closed = true
+				return
+			}
+			position.orderID = orderID
+			s.saveState(true)
+		})
+	})
+}
+
+func (s *jumpTradingSystem) getEntryCallback(position *jumpPosition) func (order managedOrder) {
+	return func (order managedOrder) {
+		if order.state == orderOpen {
+			return
+		}
+		if order.sizeMatched.IsPositive() {
+			position.timestamp = getTime()
+			position.size = order.sizeMatched
+			position.open = true
+		} else {
+			position.closed = true
+		}
+		s.saveState(true)
 	}
 }
 
+func (s *jumpTradingSystem) getExitCallback(position *jumpPosition) func (order managedOrder) {
+	return func (order managedOrder) {
+		switch order.state {
+		case orderFilled:
+			position.closed = true
+		case orderCanceled:
+			position.size = position.size.Sub(order.sizeMatched)
+			position.closed = !position.size.IsPositive()
+			position.exiting = false
+		}
+		s.saveState(true)
+	}
+}
+
+func (s *jumpTradingSystem) checkEntryTimeout(subscription *jumpSubscription) {
+	position := subscription.position
+	if position.open || position.canceling || position.orderID == "" || getTime().Sub(position.timestamp) < s.entryTimeout {
+		return
+	}
+	slug := subscription.market.Slug
+	orderID := position.orderID
+	log.Printf("Entry order %s for %s has not been filled after %s, canceling it", orderID, slug, s.entryTimeout)
+	position.canceling = true
+	s.execute(func () {
+		err := s.orders.cancel(orderID)
+		if err != nil {
+			s.loop.post(func () {
+				log.Printf("Failed to cancel entry order %s for %s: %v", orderID, slug, err)
+				position.canceling = false
+			})
+		}
+	})
+}
+
+func (s *jumpTradingSystem) checkExit(subscription *jumpSubscription) {
+	position := subscription.position
+	if !position.open || position.exiting {
+		return
+	}
+	slug := subscription.market.Slug
+	expired := getTime().Sub(position.timestamp) >= s.holdingTime
+	stopLoss := false
+	if s.stopLoss && subscription.prices.Len() > 0 {
+		price := subscription.prices.Back().price
+		stopLoss = price.GreaterThan(s.threshold3)
+	}
+	if !expired && !stopLoss {
+		return
+	}
+	if subscription.bestAsk == nil {
+		log.Printf("Unable to close position in %s, no best ask available yet", slug)
+		return
+	}
+	if subscription.integrity.stale {
+		log.Printf("Delaying exit from %s, the order book is stale", slug)
+		return
+	}
+	if expired {
+		log.Printf("Holding time of position in %s has expired", slug)
+	} else {
+		log.Printf("Stop-loss has been triggered for %s", slug)
+	}
+	limit := decimal.NewFromInt(1).Sub(*subscription.bestAsk)
+	position.exiting = true
+	tokenID := subscription.noID
+	size := position.size
+	negRisk := subscription.market.NegRisk
+	onUpdate := s.getExitCallback(position)
+	s.execute(func () {
+		orderID, err := s.orders.submit(slug, tokenID, model.SELL, size, limit, negRisk, 0, onUpdate)
+		s.loop.post(func () {
+			if err != nil {
+				log.Printf("Failed to execute order: %v", err)
+				position.exiting = false
+				return
+			}
+			position.orderID = orderID
+			s.saveState(true)
+		})
+	})
+}
+
 func addPrice(price decimal.Decimal, prices *deque.Deque[jumpPriceEvent]) {
 	event := jumpPriceEvent{
-		timestamp: time.Now(),
+		timestamp: getTime(),
 		price: price,
 	}
 	prices.PushBack(event)
-	now := time.Now()
+	now := getTime()
 	for prices.Len() > 0 {
 		price := prices.Front()
 		age := now.Sub(price.timestamp)
@@ -38,8 +38,15 @@ func listMarkets(slug string, directory string) {
 	}
 	tagID := commons.MustParseInt(tag.ID)
 	markets := []gamma.Market{}
+	scheduler := newDownloadScheduler()
 	for offset := 0;; offset += historyPageLimit {
-		marketBatch, err := gamma.GetMarkets(offset, historyPageLimit, listMarketsOrder, listMarketsFirstDate, &tagID)
+		var marketBatch []gamma.Market
+		key := fmt.Sprintf("markets at offset %d", offset)
+		err := scheduler.request(key, func () error {
+			var err error
+			marketBatch, err = gamma.GetMarkets(offset, historyPageLimit, listMarketsOrder, listMarketsFirstDate, &tagID)
+			return err
+		})
 		if err != nil {
 			log.Fatalf("Failed to get markets: %v", err)
 		}
@@ -61,13 +68,14 @@ func listMarkets(slug string, directory string) {
 			if outcome == nil {
 				continue
 			}
-			downloadEvent(market.Slug, directory)
+			downloadEventMarkets(scheduler, market.Slug, directory)
 			markets = append(markets, market)
 		}
 		if len(marketBatch) < historyPageLimit {
 			break
 		}
 	}
+	scheduler.printSummary()
 	counter := 1
 	yes := 0
 	yesPrices := []float64{}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gammazero/deque"
+)
+
+const (
+	timerInterval = 1
+)
+
+type eventLoop struct {
+	mutex sync.Mutex
+	events deque.Deque[func ()]
+	signal chan struct{}
+	synchronous bool
+}
+
+func newEventLoop(synchronous bool) *eventLoop {
+	loop := &eventLoop{
+		events: deque.Deque[func ()]{},
+		signal: make(chan struct{}, 1),
+		synchronous: synchronous,
+	}
+	if !synchronous {
+		go loop.run()
+	}
+	return loop
+}
+
+func (l *eventLoop) run() {
+	for range l.signal {
+		for {
+			l.mutex.Lock()
+			if l.events.Len() == 0 {
+				l.mutex.Unlock()
+				break
+			}
+			event := l.events.PopFront()
+			l.mutex.Unlock()
+			event()
+		}
+	}
+}
+
+func (l *eventLoop) post(event func ()) {
+	if l.synchronous {
+		event()
+		return
+	}
+	l.mutex.Lock()
+	l.events.PushBack(event)
+	l.mutex.Unlock()
+	select {
+	case l.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (l *eventLoop) invoke(event func ()) {
+	if l.synchronous {
+		event()
+		return
+	}
+	done := make(chan struct{})
+	l.post(func () {
+		event()
+		close(done)
+	})
+	<-done
+}
+
+func (l *eventLoop) startTimer(onTimer func ()) {
+	if l.synchronous {
+		return
+	}
+	go func () {
+		ticker := time.NewTicker(time.Duration(timerInterval) * time.Second)
+		for range ticker.C {
+			l.post(onTimer)
+		}
+	}()
+}
\ No newline at end of file
@@ -18,7 +18,7 @@ func main() {
 	trades := flag.String("trades", "", "Download historical trades from the event to the target directory specified by -output")
 	output := flag.String("output", "", "The directory to download the complete price history to, only works in combination with -download")
 	screener := flag.Bool("screener", false, "Filter for events that meet certain criteria")
-	backtest := flag.Bool("backtest", false, "Run backtest")
+	backtest := flag.String("backtest", "", "Run the backtest defined in the specified YAML file")
 	tags := flag.String("tags", "", "Get the tags of an event")
 	relatedTags := flag.String("related", "", "Find related tags")
 	outcomes := flag.Bool("outcomes", false, "Analyze the correlation between prices and outcomes")
@@ -28,6 +28,13 @@ func main() {
 	profitStartString := flag.String("profit-start", "", "Override standard range of -profit, limiting it to records after the specified date")
 	profitEndString := flag.String("profit-end", "", "Override standard range of -profit, limiting it to records before the specified date")
 	list := flag.String("list", "", "List markets matching a tag slug")
+	orders := flag.Bool("orders", false, "List open orders")
+	cancel := flag.String("cancel", "", "Cancel the order with the specified ID")
+	cancelAll := flag.Bool("cancel-all", false, "Cancel all open orders")
+	replay := flag.String("replay", "", "Replay recorded market data through the \"trigger\" or \"jump\" system, requires -replay-start and -replay-end")
+	replayStartString := flag.String("replay-start", "", "Start of the time range replayed by -replay")
+	replayEndString := flag.String("replay-end", "", "End of the time range replayed by -replay")
+	notify := flag.Bool("notify", false, "Send a test alert to all notification sinks defined in the configuration file")
 	flag.Parse()
 	if *dataMode {
 		runMode(systemDataMode)
@@ -47,8 +54,8 @@ func main() {
 		downloadTrades(*trades, *output)
 	} else if *screener {
 		runScreener()
-	} else if *backtest {
-		runBacktest()
+	} else if *backtest != "" {
+		runBacktest(*backtest)
 	} else if *tags != "" {
 		showEventTags(*tags)
 	} else if *relatedTags != "" {
@@ -72,6 +79,18 @@ func main() {
 		analyzeProfits(profitStart, profitEnd)
 	} else if *list != "" && *output != "" {
 		listMarkets(*list, *output)
+	} else if *orders {
+		showOpenOrders()
+	} else if *cancel != "" {
+		cancelOrder(*cancel)
+	} else if *cancelAll {
+		cancelAllOrders()
+	} else if *replay != "" && *replayStartString != "" && *replayEndString != "" {
+		replayStart := commons.MustParseTime(*replayStartString)
+		replayEnd := commons.MustParseTime(*replayEndString)
+		runReplay(*replay, replayStart, replayEnd)
+	} else if *notify {
+		testNotifications()
 	} else {
 		flag.Usage()
 	}
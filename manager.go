@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/polymarket/go-order-utils/pkg/model"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	orderPollInterval = 5
+)
+
+type orderManager struct {
+	mutex sync.Mutex
+	polling sync.Mutex
+	dispatch func (event func ())
+	executor orderExecutor
+	orders map[string]*managedOrder
+	dryRunOrders int
+	risk *riskManager
+}
+
+type managedOrder struct {
+	id string
+	slug string
+	tokenID string
+	side model.Side
+	size decimal.Decimal
+	limit decimal.Decimal
+	negRisk bool
+	posted time.Time
+	sizeMatched decimal.Decimal
+	cost decimal.Decimal
+	state orderState
+	onUpdate func (order managedOrder)
+}
+
+func newOrderManager(executor orderExecutor) *orderManager {
+	return &orderManager{
+		dispatch: func (event func ()) {
+			event()
+		},
+		executor: executor,
+		orders: map[string]*managedOrder{},
+		dryRunOrders: 0,
+		risk: nil,
+	}
+}
+
+func (m *orderManager) enableRiskManagement(live bool) {
+	m.risk = newRiskManager(live)
+	m.risk.flatten = m.flatten
+}
+
+func (m *orderManager) run() {
+	go func() {
+		for {
+			time.Sleep(time.Duration(orderPollInterval) * time.Second)
+			m.poll()
+		}
+	}()
+}
+
+func (m *orderManager) submit(
+	slug string,
+	tokenID string,
+	side model.Side,
+	size decimal.Decimal,
+	limit decimal.Decimal,
+	negRisk bool,
+	expiration int,
+	onUpdate func (order managedOrder),
+) (string, error) {
+	if m.risk != nil {
+		err := m.risk.check(slug, tokenID, side, size, limit)
+		if err != nil {
+			return "", err
+		}
+	}
+	orderID, err := m.executor.postOrder(slug, tokenID, side, size, limit, negRisk, expiration)
+	if err != nil {
+		return "", err
+	}
+	order := &managedOrder{
+		id: orderID,
+		slug: slug,
+		tokenID: tokenID,
+		side: side,
+		size: size,
+		limit: limit,
+		negRisk: negRisk,
+		posted: getTime(),
+		sizeMatched: decimal.Zero,
+		cost: decimal.Zero,
+		state: orderOpen,
+		onUpdate: onUpdate,
+	}
+	if orderID == "" {
+		m.mutex.Lock()
+		m.dryRunOrders++
+		order.id = fmt.Sprintf("dry-run-%d", m.dryRunOrders)
+		m.mutex.Unlock()
+		log.Printf("Treating dry run order %s for %s as filled", order.id, slug)
+		order.sizeMatched = size
+		order.cost = size.Mul(limit)
+		order.state = orderFilled
+		m.onFill(*order, size, limit)
+		update := *order
+		m.dispatch(func () {
+			onUpdate(update)
+		})
+		return order.id, nil
+	}
+	m.mutex.Lock()
+	m.orders[orderID] = order
+	m.mutex.Unlock()
+	log.Printf("Tracking order %s for %s", orderID, slug)
+	return orderID, nil
+}
+
+func (m *orderManager) track(state TrackedOrderState, onUpdate func (order managedOrder)) {
+	order := &managedOrder{
+		id: state.ID,
+		slug: state.Slug,
+		tokenID: state.TokenID,
+		side: getModelSide(state.Side),
+		size: state.Size,
+		limit: state.Limit,
+		negRisk: state.NegRisk,
+		posted: state.Posted,
+		sizeMatched: state.SizeMatched,
+		cost: state.Cost,
+		state: orderOpen,
+		onUpdate: onUpdate,
+	}
+	if order.posted.IsZero() {
+		order.posted = getTime()
+	}
+	if order.cost.IsZero() {
+		order.cost = order.sizeMatched.Mul(order.limit)
+	}
+	m.mutex.Lock()
+	m.orders[order.id] = order
+	m.mutex.Unlock()
+	log.Printf("Resumed tracking order %s for %s", order.id, order.slug)
+}
+
+func (m *orderManager) poll() {
+	m.polling.Lock()
+	defer m.polling.Unlock()
+	m.mutex.Lock()
+	orders := []*managedOrder{}
+	for _, order := range m.orders {
+		orders = append(orders, order)
+	}
+	m.mutex.Unlock()
+	for _, order := range orders {
+		status, err := m.executor.getOrder(order.id)
+		if err != nil {
+			log.Printf("Failed to retrieve status of order %s: %v", order.id, err)
+			continue
+		}
+		if status.state == order.state && status.sizeMatched.Equal(order.sizeMatched) {
+			continue
+		}
+		filled := status.sizeMatched.Sub(order.sizeMatched)
+		price := order.limit
+		if filled.IsPositive() && status.cost.GreaterThan(order.cost) {
+			price = status.cost.Sub(order.cost).Div(filled)
+		}
+		order.state = status.state
+		order.sizeMatched = status.sizeMatched
+		order.cost = status.cost
+		m.onFill(*order, filled, price)
+		switch order.state {
+		case orderOpen:
+			log.Printf("Order %s for %s has been partially filled: %s of %s", order.id, order.slug, order.sizeMatched, order.size)
+		case orderFilled:
+			log.Printf("Order %s for %s has been filled", order.id, order.slug)
+		case orderCanceled:
+			log.Printf("Order %s for %s has been canceled after filling %s of %s", order.id, order.slug, order.sizeMatched, order.size)
+		}
+		if order.state != orderOpen {
+			m.mutex.Lock()
+			delete(m.orders, order.id)
+			m.mutex.Unlock()
+		}
+		update := *order
+		m.dispatch(func () {
+			order.onUpdate(update)
+		})
+	}
+}
+
+func (m *orderManager) onFill(order managedOrder, quantity, price decimal.Decimal) {
+	if m.risk != nil {
+		m.risk.onFill(order, quantity, price)
+	}
+}
+
+func (m *orderManager) getRiskState() *RiskState {
+	if m.risk == nil {
+		return nil
+	}
+	return m.risk.getState()
+}
+
+func (m *orderManager) restoreRisk(state *RiskState) {
+	if m.risk != nil && state != nil {
+		m.risk.restore(*state)
+	}
+}
+
+func (m *orderManager) isFrozen() bool {
+	if m.risk == nil {
+		return false
+	}
+	return m.risk.isFrozen()
+}
+
+func (m *orderManager) flatten() {
+	log.Printf("Flattening all positions")
+	err := m.cancelAll()
+	if err != nil {
+		log.Printf("Failed to cancel orders: %v", err)
+	}
+	limit := decimalConstant(flattenLimit)
+	for _, order := range m.risk.getFlattenOrders() {
+		position := order.position
+		_, err := m.submit(position.slug, position.tokenID, model.SELL, position.size, limit, order.negRisk, 0, func (managedOrder) {})
+		if err != nil {
+			log.Printf("Failed to flatten position in %s: %v", position.slug, err)
+		}
+	}
+}
+
+func (m *orderManager) cancel(orderID string) error {
+	err := m.executor.cancelOrder(orderID)
+	if err != nil {
+		return err
+	}
+	log.Printf("Requested cancellation of order %s", orderID)
+	m.poll()
+	return nil
+}
+
+func (m *orderManager) cancelAll() error {
+	err := m.executor.cancelAllOrders()
+	if err != nil {
+		return err
+	}
+	log.Printf("Requested cancellation of all orders")
+	m.poll()
+	return nil
+}
+
+func (m *orderManager) getOpenOrders() []managedOrder {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	orders := []managedOrder{}
+	for _, order := range m.orders {
+		orders = append(orders, *order)
+	}
+	return orders
+}
+
+func (m *orderManager) getOrderStates() []TrackedOrderState {
+	states := []TrackedOrderState{}
+	for _, order := range m.getOpenOrders() {
+		state := TrackedOrderState{
+			ID: order.id,
+			Slug: order.slug,
+			TokenID: order.tokenID,
+			Side: getOrderSide(order.side),
+			Size: order.size,
+			Limit: order.limit,
+			NegRisk: order.negRisk,
+			Posted: order.posted,
+			SizeMatched: order.sizeMatched,
+			Cost: order.cost,
+		}
+		states = append(states, state)
+	}
+	slices.SortFunc(states, func (a, b TrackedOrderState) int {
+		return a.Posted.Compare(b.Posted)
+	})
+	return states
+}
+
+func showOpenOrders() {
+	loadConfiguration()
+	orders, err := getOpenOrders()
+	if err != nil {
+		log.Fatalf("Failed to retrieve open orders: %v", err)
+	}
+	if len(orders) == 0 {
+		fmt.Printf("No open orders\n")
+		return
+	}
+	for _, order := range orders {
+		fmt.Printf("%s: asset = %s, side = %s, price = %s, size = %s, matched = %s, status = %s\n", order.ID, order.AssetID, order.Side, order.Price, order.OriginalSize, order.SizeMatched, order.Status)
+	}
+}
+
+func cancelOrder(orderID string) {
+	loadConfiguration()
+	executor := newCLOBExecutor(true)
+	err := executor.cancelOrder(orderID)
+	if err != nil {
+		log.Fatalf("Failed to cancel order: %v", err)
+	}
+	log.Printf("Canceled order %s", orderID)
+}
+
+func cancelAllOrders() {
+	loadConfiguration()
+	executor := newCLOBExecutor(true)
+	err := executor.cancelAllOrders()
+	if err != nil {
+		log.Fatalf("Failed to cancel all orders: %v", err)
+	}
+}
\ No newline at end of file
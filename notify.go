@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+type alertSeverity string
+
+const (
+	alertInfo alertSeverity = "info"
+	alertWarning alertSeverity = "warning"
+	alertCritical alertSeverity = "critical"
+)
+
+type notifier interface {
+	notify(alert Alert) error
+}
+
+type Alert struct {
+	Timestamp time.Time `json:"timestamp"`
+	Severity alertSeverity `json:"severity"`
+	Slug string `json:"slug"`
+	Message string `json:"message"`
+}
+
+type beepNotifier struct {}
+
+type desktopNotifier struct {}
+
+type alertLogNotifier struct {
+	mutex sync.Mutex
+	path string
+}
+
+type webhookNotifier struct {
+	url string
+	client *http.Client
+}
+
+type emailNotifier struct {
+	address string
+	auth smtp.Auth
+	from string
+	to []string
+}
+
+var notifiers []notifier
+var notifierMutex sync.Mutex
+var alertGroup sync.WaitGroup
+
+func initializeNotifiers() {
+	config := getConfiguration().Notifications
+	next := []notifier{}
+	if *config.Beep {
+		next = append(next, &beepNotifier{})
+	}
+	if *config.Desktop {
+		next = append(next, &desktopNotifier{})
+	}
+	if config.AlertLog != nil {
+		alertLog := &alertLogNotifier{
+			path: *config.AlertLog,
+		}
+		next = append(next, alertLog)
+	}
+	if config.Webhook != nil {
+		webhook := &webhookNotifier{
+			url: *config.Webhook.URL,
+			client: &http.Client{
+				Timeout: time.Duration(*config.Webhook.Timeout) * time.Second,
+			},
+		}
+		next = append(next, webhook)
+	}
+	if config.Email != nil {
+		email := config.Email
+		var auth smtp.Auth
+		if email.Username != nil && email.Password != nil {
+			auth = smtp.PlainAuth("", *email.Username, *email.Password, *email.Host)
+		}
+		emailNotifier := &emailNotifier{
+			address: fmt.Sprintf("%s:%d", *email.Host, *email.Port),
+			auth: auth,
+			from: *email.From,
+			to: email.To,
+		}
+		next = append(next, emailNotifier)
+	}
+	setNotifiers(next)
+}
+
+func getNotifiers() []notifier {
+	notifierMutex.Lock()
+	defer notifierMutex.Unlock()
+	return notifiers
+}
+
+func setNotifiers(next []notifier) {
+	notifierMutex.Lock()
+	defer notifierMutex.Unlock()
+	notifiers = next
+}
+
+func sendAlert(severity alertSeverity, slug string, format string, arguments ...any) {
+	alert := Alert{
+		Timestamp: time.Now().UTC(),
+		Severity: severity,
+		Slug: slug,
+		Message: fmt.Sprintf(format, arguments...),
+	}
+	for _, n := range getNotifiers() {
+		alertGroup.Add(1)
+		go func () {
+			defer alertGroup.Done()
+			err := n.notify(alert)
+			if err != nil {
+				log.Printf("Failed to send alert via %T: %v", n, err)
+			}
+		}()
+	}
+}
+
+func waitForAlerts() {
+	alertGroup.Wait()
+}
+
+func testNotifications() {
+	loadConfiguration()
+	log.Printf("Sending test alert to %d notifiers", len(getNotifiers()))
+	sendAlert(alertInfo, "test", "This is a test alert")
+	waitForAlerts()
+}
+
+func (a Alert) getTitle() string {
+	title := fmt.Sprintf("cyclobs %s", a.Severity)
+	if a.Slug != "" {
+		title = fmt.Sprintf("%s: %s", title, a.Slug)
+	}
+	return title
+}
+
+func (n *beepNotifier) notify(alert Alert) error {
+	beep()
+	return nil
+}
+
+func (n *desktopNotifier) notify(alert Alert) error {
+	urgency := "normal"
+	switch alert.Severity {
+	case alertInfo:
+		urgency = "low"
+	case alertCritical:
+		urgency = "critical"
+	}
+	command := exec.Command("notify-send", "--urgency", urgency, alert.getTitle(), alert.Message)
+	return command.Run()
+}
+
+func (n *alertLogNotifier) notify(alert Alert) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	bytes, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(n.path, os.O_APPEND | os.O_CREATE | os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(append(bytes, '\n'))
+	return err
+}
+
+func (n *webhookNotifier) notify(alert Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	response, err := n.client.Post(n.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook returned status code %d", response.StatusCode)
+	}
+	return nil
+}
+
+func (n *emailNotifier) notify(alert Alert) error {
+	headers := []string{
+		fmt.Sprintf("From: %s", n.from),
+		fmt.Sprintf("To: %s", strings.Join(n.to, ", ")),
+		fmt.Sprintf("Subject: %s", alert.getTitle()),
+		fmt.Sprintf("Date: %s", alert.Timestamp.Format(time.RFC1123Z)),
+		"Content-Type: text/plain; charset=UTF-8",
+	}
+	body := fmt.Sprintf("%s\r\n\r\n%s\r\n\r\nTime: %s\r\n", strings.Join(headers, "\r\n"), alert.Message, commons.GetTimeString(alert.Timestamp))
+	return smtp.SendMail(n.address, n.auth, n.from, n.to, []byte(body))
+}
\ No newline at end of file
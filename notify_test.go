@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWebhookAlert(t *testing.T) {
+	payloads := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func (writer http.ResponseWriter, request *http.Request) {
+		if request.Method != http.MethodPost {
+			t.Errorf("expected POST request, got %s", request.Method)
+		}
+		contentType := request.Header.Get("Content-Type")
+		if contentType != "application/json" {
+			t.Errorf("expected JSON content type, got %s", contentType)
+		}
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			t.Errorf("failed to read request body: %v", err)
+		}
+		payloads <- body
+	}))
+	defer server.Close()
+	loadNotifyTestConfiguration(t, server.URL)
+	if len(getNotifiers()) != 1 {
+		t.Fatalf("expected 1 notifier, got %d", len(getNotifiers()))
+	}
+	before := time.Now().UTC()
+	sendAlert(alertCritical, "notify-test-market", "Risk limit breached: %s (action: %s)", "daily loss", riskBreachFreeze)
+	waitForAlerts()
+	var body []byte
+	select {
+	case body = <-payloads:
+	default:
+		t.Fatalf("webhook did not receive an alert")
+	}
+	fields := map[string]any{}
+	err := json.Unmarshal(body, &fields)
+	if err != nil {
+		t.Fatalf("failed to deserialize payload %s: %v", body, err)
+	}
+	expected := map[string]string{
+		"severity": "critical",
+		"slug": "notify-test-market",
+		"message": "Risk limit breached: daily loss (action: freeze)",
+	}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("expected %s to be \"%s\", got %v", key, value, fields[key])
+		}
+	}
+	timestampString, ok := fields["timestamp"].(string)
+	if !ok {
+		t.Fatalf("missing timestamp in payload %s", body)
+	}
+	timestamp, err := time.Parse(time.RFC3339Nano, timestampString)
+	if err != nil {
+		t.Fatalf("invalid timestamp %s: %v", timestampString, err)
+	}
+	if timestamp.Before(before.Add(-time.Second)) || timestamp.After(time.Now().Add(time.Second)) {
+		t.Errorf("unexpected timestamp %s", timestamp)
+	}
+	if len(fields) != len(expected) + 1 {
+		t.Errorf("unexpected fields in payload %s", body)
+	}
+}
+
+func TestWebhookAlertStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func (writer http.ResponseWriter, request *http.Request) {
+		writer.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	loadNotifyTestConfiguration(t, server.URL)
+	alert := Alert{
+		Timestamp: time.Now().UTC(),
+		Severity: alertWarning,
+		Slug: "",
+		Message: "test",
+	}
+	err := getNotifiers()[0].notify(alert)
+	if err == nil {
+		t.Fatalf("expected an error for a failed webhook request")
+	}
+}
+
+func loadNotifyTestConfiguration(t *testing.T, url string) {
+	beep := false
+	config := &Configuration{
+		Notifications: NotificationConfiguration{
+			Beep: &beep,
+			Webhook: &WebhookConfiguration{
+				URL: &url,
+			},
+		},
+	}
+	err := config.Notifications.validate()
+	if err != nil {
+		t.Fatalf("Invalid notification configuration: %v", err)
+	}
+	setConfiguration(config)
+	initializeNotifiers()
+}
\ No newline at end of file
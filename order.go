@@ -12,6 +12,7 @@ import (
 	"math/big"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/encratite/commons"
@@ -31,6 +32,18 @@ const (
 	sideSell = "SELL"
 	orderTypeGTC = "GTC"
 	orderTypeGTD = "GTD"
+	clobURL = "https://clob.polymarket.com"
+	clobStatusMatched = "MATCHED"
+	clobStatusCanceled = "CANCELED"
+	clobStatusUnmatched = "UNMATCHED"
+)
+
+type orderState int
+
+const (
+	orderOpen orderState = iota
+	orderFilled
+	orderCanceled
 )
 
 type NewOrder struct {
@@ -65,7 +78,233 @@ type OrderResponse struct {
 	Success bool `json:"success"`
 }
 
-func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limit decimal.Decimal, negRisk bool, expiration int) error {
+type OpenOrder struct {
+	ID string `json:"id"`
+	Status string `json:"status"`
+	Market string `json:"market"`
+	AssetID string `json:"asset_id"`
+	Side string `json:"side"`
+	OriginalSize string `json:"original_size"`
+	SizeMatched string `json:"size_matched"`
+	Price string `json:"price"`
+	Expiration string `json:"expiration"`
+	OrderType string `json:"order_type"`
+	CreatedAt int64 `json:"created_at"`
+	AssociateTrades []string `json:"associate_trades"`
+}
+
+type CLOBTrade struct {
+	ID string `json:"id"`
+	TakerOrderID string `json:"taker_order_id"`
+	AssetID string `json:"asset_id"`
+	Size string `json:"size"`
+	Price string `json:"price"`
+	MakerOrders []CLOBMakerOrder `json:"maker_orders"`
+}
+
+type CLOBMakerOrder struct {
+	OrderID string `json:"order_id"`
+	AssetID string `json:"asset_id"`
+	MatchedAmount string `json:"matched_amount"`
+	Price string `json:"price"`
+}
+
+type CLOBTradesResponse struct {
+	Data []CLOBTrade `json:"data"`
+	NextCursor string `json:"next_cursor"`
+}
+
+type OpenOrdersResponse struct {
+	Data []OpenOrder `json:"data"`
+	NextCursor string `json:"next_cursor"`
+}
+
+type CancelOrderRequest struct {
+	OrderID string `json:"orderID"`
+}
+
+type CancelResponse struct {
+	Canceled []string `json:"canceled"`
+	NotCanceled map[string]string `json:"not_canceled"`
+}
+
+type orderStatus struct {
+	state orderState
+	sizeMatched decimal.Decimal
+	cost decimal.Decimal
+}
+
+type orderExecutor interface {
+	postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limit decimal.Decimal, negRisk bool, expiration int) (string, error)
+	getOrder(orderID string) (orderStatus, error)
+	cancelOrder(orderID string) error
+	cancelAllOrders() error
+}
+
+type clobExecutor struct {
+	mutex sync.Mutex
+	live bool
+	tradeCosts map[string]decimal.Decimal
+}
+
+func newCLOBExecutor(live bool) *clobExecutor {
+	return &clobExecutor{
+		live: live,
+		tradeCosts: map[string]decimal.Decimal{},
+	}
+}
+
+func (e *clobExecutor) postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limit decimal.Decimal, negRisk bool, expiration int) (string, error) {
+	return postOrder(slug, tokenID, side, size, limit, negRisk, expiration, e.live)
+}
+
+func (e *clobExecutor) getOrder(orderID string) (orderStatus, error) {
+	var order OpenOrder
+	err := sendCLOBRequest(http.MethodGet, "/data/order/" + orderID, nil, &order)
+	if err != nil {
+		return orderStatus{}, err
+	}
+	sizeMatched, err := decimal.NewFromString(order.SizeMatched)
+	if err != nil {
+		return orderStatus{}, fmt.Errorf("invalid matched size in order %s: %s", orderID, order.SizeMatched)
+	}
+	state := orderOpen
+	switch order.Status {
+	case clobStatusMatched:
+		state = orderFilled
+	case clobStatusCanceled, clobStatusUnmatched:
+		state = orderCanceled
+	}
+	cost := decimal.Zero
+	if sizeMatched.IsPositive() {
+		cost, err = e.getFillCost(order)
+		if err != nil {
+			log.Printf("Failed to determine the fill price of order %s, using its limit: %v", orderID, err)
+			price, priceErr := decimal.NewFromString(order.Price)
+			if priceErr != nil {
+				return orderStatus{}, fmt.Errorf("invalid price in order %s: %s", orderID, order.Price)
+			}
+			cost = sizeMatched.Mul(price)
+		}
+	}
+	status := orderStatus{
+		state: state,
+		sizeMatched: sizeMatched,
+		cost: cost,
+	}
+	return status, nil
+}
+
+func (e *clobExecutor) getFillCost(order OpenOrder) (decimal.Decimal, error) {
+	cost := decimal.Zero
+	for _, tradeID := range order.AssociateTrades {
+		e.mutex.Lock()
+		tradeCost, exists := e.tradeCosts[tradeID]
+		e.mutex.Unlock()
+		if !exists {
+			var response CLOBTradesResponse
+			err := sendCLOBRequest(http.MethodGet, "/data/trades?id=" + tradeID, nil, &response)
+			if err != nil {
+				return decimal.Zero, err
+			}
+			trade, exists := commons.Find(response.Data, func (t CLOBTrade) bool {
+				return t.ID == tradeID
+			})
+			if !exists {
+				return decimal.Zero, fmt.Errorf("trade %s of order %s not found", tradeID, order.ID)
+			}
+			tradeCost, err = getTradeCost(trade, order)
+			if err != nil {
+				return decimal.Zero, err
+			}
+			e.mutex.Lock()
+			e.tradeCosts[tradeID] = tradeCost
+			e.mutex.Unlock()
+		}
+		cost = cost.Add(tradeCost)
+	}
+	return cost, nil
+}
+
+func (e *clobExecutor) cancelOrder(orderID string) error {
+	if !e.live {
+		log.Printf("Not canceling order %s, system is not live", orderID)
+		return nil
+	}
+	cancelRequest := CancelOrderRequest{
+		OrderID: orderID,
+	}
+	var cancelResponse CancelResponse
+	err := sendCLOBRequest(http.MethodDelete, "/order", cancelRequest, &cancelResponse)
+	if err != nil {
+		return err
+	}
+	reason, exists := cancelResponse.NotCanceled[orderID]
+	if exists {
+		return fmt.Errorf("failed to cancel order %s: %s", orderID, reason)
+	}
+	return nil
+}
+
+func (e *clobExecutor) cancelAllOrders() error {
+	if !e.live {
+		log.Printf("Not canceling all orders, system is not live")
+		return nil
+	}
+	var cancelResponse CancelResponse
+	err := sendCLOBRequest(http.MethodDelete, "/cancel-all", nil, &cancelResponse)
+	if err != nil {
+		return err
+	}
+	log.Printf("Canceled %d orders", len(cancelResponse.Canceled))
+	if len(cancelResponse.NotCanceled) > 0 {
+		return fmt.Errorf("failed to cancel %d orders", len(cancelResponse.NotCanceled))
+	}
+	return nil
+}
+
+func getTradeCost(trade CLOBTrade, order OpenOrder) (decimal.Decimal, error) {
+	cost := decimal.Zero
+	for _, makerOrder := range trade.MakerOrders {
+		if trade.TakerOrderID != order.ID && makerOrder.OrderID != order.ID {
+			continue
+		}
+		price, size, err := getPriceSize(makerOrder.Price, makerOrder.MatchedAmount)
+		if err != nil {
+			return decimal.Zero, fmt.Errorf("invalid maker order in trade %s: %v", trade.ID, err)
+		}
+		if makerOrder.AssetID != order.AssetID {
+			price = decimal.NewFromInt(1).Sub(price)
+		}
+		cost = cost.Add(price.Mul(size))
+	}
+	return cost, nil
+}
+
+func getOpenOrders() ([]OpenOrder, error) {
+	const endCursor = "LTE="
+	orders := []OpenOrder{}
+	cursor := ""
+	for cursor != endCursor {
+		requestPath := "/data/orders"
+		if cursor != "" {
+			requestPath += "?next_cursor=" + cursor
+		}
+		var response OpenOrdersResponse
+		err := sendCLOBRequest(http.MethodGet, requestPath, nil, &response)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, response.Data...)
+		if response.NextCursor == "" {
+			break
+		}
+		cursor = response.NextCursor
+	}
+	return orders, nil
+}
+
+func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limit decimal.Decimal, negRisk bool, expiration int, live bool) (string, error) {
 	if len(tokenID) < 20 {
 		log.Fatalf("Invalid tokenID")
 	}
@@ -83,7 +322,8 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 	if side == model.SELL {
 		sideString = sideSell
 	}
-	beep()
+	credentials := getConfiguration().Credentials
+	sendAlert(alertInfo, slug, "Posting %s order: size = %s, limit = %s, live = %t", sideString, size, limit.Round(2), live)
 	limit = limit.Round(2)
 	bigChainId := big.NewInt(chainId)
 	orderBuilder := builder.NewExchangeOrderBuilderImpl(bigChainId, nil)
@@ -96,9 +336,9 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 		makerAmount = makerSwap
 		takerAmount = takerSwap
 	}
-	if !*configuration.Trigger.Live {
+	if !live {
 		log.Printf("Not posting %s order for %s, system is not live", sideString, slug)
-		return nil
+		return "", nil
 	}
 	format := "Posting order: slug = %s, tokenID = %s, side = %s, size = %s, limit = %s, negRisk = %t, expiration = %d, makerAmount = %d, takerAmount = %d"
 	log.Printf(format, slug, tokenID, sideString, size, limit, negRisk, expiration, makerAmount, takerAmount)
@@ -111,8 +351,8 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 		expirationString = "0"
 	}
 	orderData := model.OrderData{
-		Maker: configuration.Credentials.ProxyAddress,
-		Signer: configuration.Credentials.PolygonAddress,
+		Maker: credentials.ProxyAddress,
+		Signer: credentials.PolygonAddress,
 		Taker: "0x0000000000000000000000000000000000000000",
 		TokenId: tokenID,
 		MakerAmount: commons.Int64ToString(makerAmount),
@@ -135,7 +375,7 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 	if err != nil {
 		log.Fatalf("Failed to build order hash: %v", err)
 	}
-	privateKeyString := configuration.Credentials.PrivateKey
+	privateKeyString := credentials.PrivateKey
 	if privateKeyString[:len(hexPrefix)] == hexPrefix {
 		privateKeyString = privateKeyString[len(hexPrefix):]
 	}
@@ -149,10 +389,6 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 		log.Fatalf("Failed to build order signature: %v", err)
 	}
 	orderSignatureString := hexPrefix + common.Bytes2Hex(orderSignature)
-	now := time.Now().UTC()
-	timestamp := now.Unix()
-	method := "POST"
-	requestPath := "/order"
 	order := Order{
 		Salt: orderModel.Salt.Int64(),
 		Maker: orderData.Maker,
@@ -175,17 +411,37 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 	newOrder := NewOrder{
 		DeferExec: false,
 		Order: order,
-		Owner: configuration.Credentials.APIKey,
+		Owner: credentials.APIKey,
 		OrderType: orderType,
 	}
-	bodyBytes, err := json.Marshal(newOrder)
+	fmt.Printf("Order signature: %s\n", orderSignatureString)
+	var orderResponse OrderResponse
+	err = sendCLOBRequest(http.MethodPost, "/order", newOrder, &orderResponse)
 	if err != nil {
-		log.Fatalf("Failed to serialize order: %v", err)
+		return "", err
+	}
+	if !orderResponse.Success {
+		return "", fmt.Errorf("Failed to post order: %s", orderResponse.ErrorMsg)
+	}
+	return orderResponse.OrderID, nil
+}
+
+func sendCLOBRequest(method, requestPath string, requestBody any, output any) error {
+	var bodyBytes []byte
+	if requestBody != nil {
+		var err error
+		bodyBytes, err = json.Marshal(requestBody)
+		if err != nil {
+			log.Fatalf("Failed to serialize request body: %v", err)
+		}
 	}
 	body := string(bodyBytes)
+	credentials := getConfiguration().Credentials
+	timestamp := time.Now().UTC().Unix()
 	timestampString := commons.Int64ToString(timestamp)
-	message := timestampString + method + requestPath + body
-	secretBytes, err := base64.StdEncoding.DecodeString(configuration.Credentials.Secret)
+	signedPath := strings.Split(requestPath, "?")[0]
+	message := timestampString + method + signedPath + body
+	secretBytes, err := base64.StdEncoding.DecodeString(credentials.Secret)
 	if err != nil {
 		log.Fatalf("Failed to decode secret: %v", err)
 	}
@@ -195,41 +451,38 @@ func postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limi
 	hmacSignature := base64.StdEncoding.EncodeToString(hashBytes)
 	hmacSignature = strings.ReplaceAll(hmacSignature, "+", "-")
 	hmacSignature = strings.ReplaceAll(hmacSignature, "/", "_")
-	fmt.Printf("Order signature: %s\n", orderSignatureString)
-	fmt.Printf("HMAC signature: %s\n", hmacSignature)
-	url := "https://clob.polymarket.com/order"
+	url := clobURL + requestPath
 	buffer := bytes.NewBuffer(bodyBytes)
 	request, err := http.NewRequest(method, url, buffer)
 	if err != nil {
 		log.Fatalf("Failed to create HTTP request: %v", err)
 	}
 	request.Header.Set("Content-Type", "application/json")
-	request.Header.Set("POLY_ADDRESS", configuration.Credentials.PolygonAddress)
-	request.Header.Set("POLY_API_KEY", configuration.Credentials.APIKey)
-	request.Header.Set("POLY_PASSPHRASE", configuration.Credentials.Passphrase)
+	request.Header.Set("POLY_ADDRESS", credentials.PolygonAddress)
+	request.Header.Set("POLY_API_KEY", credentials.APIKey)
+	request.Header.Set("POLY_PASSPHRASE", credentials.Passphrase)
 	request.Header.Set("POLY_SIGNATURE", hmacSignature)
 	request.Header.Set("POLY_TIMESTAMP", timestampString)
 	client := &http.Client{}
 	response, err := client.Do(request)
 	if err != nil {
-		log.Printf("Failed to POST order: %v", err)
+		log.Printf("Failed to send %s %s request: %v", method, signedPath, err)
 		return err
 	}
 	defer response.Body.Close()
 	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		log.Printf("Failed to read response after posting order: %v", err)
+		log.Printf("Failed to read response of %s %s request: %v", method, signedPath, err)
 		return err
 	}
-	log.Printf("Order response: %s", responseBody)
-	var orderResponse OrderResponse
-	err = json.Unmarshal(responseBody, &orderResponse)
+	if response.StatusCode != http.StatusOK {
+		log.Printf("CLOB response: %s", responseBody)
+		return fmt.Errorf("%s %s request failed with status code %d", method, signedPath, response.StatusCode)
+	}
+	err = json.Unmarshal(responseBody, output)
 	if err != nil {
-		log.Printf("Failed to deserialize order response: %v", err)
+		log.Printf("Failed to deserialize response of %s %s request: %v", method, signedPath, err)
 		return err
 	}
-	if !orderResponse.Success {
-		return fmt.Errorf("Failed to post order: %s", orderResponse.ErrorMsg)
-	}
 	return nil
 }
\ No newline at end of file
@@ -19,11 +19,11 @@ func analyzeOutcomes() {
 
 func analyzeOutcomesByTag(tag string) {
 	loadConfiguration()
-	database := newDatabaseClient()
+	database := newStorage()
 	defer database.close()
 	closed := true
 	negRisk := false
-	historyData := database.getPriceHistoryData(&closed, &negRisk, nil, &tag)
+	historyData := database.getPriceHistoryData(getHistoryFidelity(), &closed, &negRisk, nil, &tag)
 	const (
 		priceOffset = 24
 		priceBinCount = 10
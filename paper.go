@@ -0,0 +1,520 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/encratite/commons"
+	"github.com/encratite/gamma"
+	"github.com/polymarket/go-order-utils/pkg/model"
+	"github.com/shopspring/decimal"
+)
+
+type paperBroker struct {
+	mutex sync.Mutex
+	path string
+	ledger PaperLedger
+	books map[string]paperBook
+	complements map[string]string
+	orders []*paperOrder
+	history map[string]*paperOrder
+	nextOrderID int
+}
+
+type paperBook struct {
+	bids *treemap.Map
+	asks *treemap.Map
+	consumedBids *treemap.Map
+	consumedAsks *treemap.Map
+}
+
+type paperOrder struct {
+	id string
+	timestamp time.Time
+	expiration *time.Time
+	slug string
+	tokenID string
+	bookID string
+	side model.Side
+	complement bool
+	buy bool
+	limit decimal.Decimal
+	size decimal.Decimal
+	remaining decimal.Decimal
+	cost decimal.Decimal
+	queueAhead decimal.Decimal
+	canceled bool
+}
+
+type PaperLedger struct {
+	Cash decimal.Decimal `json:"cash"`
+	RealizedProfit decimal.Decimal `json:"realizedProfit"`
+	Positions map[string]*PaperPosition `json:"positions"`
+	Fills []PaperFill `json:"fills"`
+	NextOrderID int `json:"nextOrderId"`
+	Orders []PaperOrderState `json:"orders"`
+}
+
+type PaperPosition struct {
+	Slug string `json:"slug"`
+	Size decimal.Decimal `json:"size"`
+	Cost decimal.Decimal `json:"cost"`
+}
+
+type PaperFill struct {
+	Timestamp time.Time `json:"timestamp"`
+	Slug string `json:"slug"`
+	TokenID string `json:"tokenId"`
+	Side string `json:"side"`
+	Price decimal.Decimal `json:"price"`
+	Size decimal.Decimal `json:"size"`
+}
+
+type PaperOrderState struct {
+	ID string `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Expiration *time.Time `json:"expiration"`
+	Slug string `json:"slug"`
+	TokenID string `json:"tokenId"`
+	BookID string `json:"bookId"`
+	Side string `json:"side"`
+	Complement bool `json:"complement"`
+	Buy bool `json:"buy"`
+	Limit decimal.Decimal `json:"limit"`
+	Size decimal.Decimal `json:"size"`
+	Remaining decimal.Decimal `json:"remaining"`
+	Cost decimal.Decimal `json:"cost"`
+	QueueAhead decimal.Decimal `json:"queueAhead"`
+	Canceled bool `json:"canceled"`
+}
+
+func newPaperBroker() *paperBroker {
+	config := getConfiguration().Paper
+	err := config.validate()
+	if err != nil {
+		log.Fatalf("Invalid paper trading configuration: %v", err)
+	}
+	broker := paperBroker{
+		path: *config.Ledger,
+		books: map[string]paperBook{},
+		complements: map[string]string{},
+		orders: []*paperOrder{},
+		history: map[string]*paperOrder{},
+		nextOrderID: 1,
+	}
+	broker.load(config.InitialCash.Decimal)
+	log.Printf("Warning: system is in paper trading mode, orders are simulated against the live order book")
+	return &broker
+}
+
+func (b *paperBroker) load(initialCash decimal.Decimal) {
+	if !commons.FileExists(b.path) {
+		b.ledger = PaperLedger{
+			Cash: initialCash,
+			RealizedProfit: decimal.Zero,
+			Positions: map[string]*PaperPosition{},
+			Fills: []PaperFill{},
+			NextOrderID: b.nextOrderID,
+			Orders: []PaperOrderState{},
+		}
+		log.Printf("Created new paper ledger with %s in cash", initialCash)
+		return
+	}
+	bytes, err := os.ReadFile(b.path)
+	if err != nil {
+		log.Fatalf("Failed to read paper ledger %s: %v", b.path, err)
+	}
+	err = json.Unmarshal(bytes, &b.ledger)
+	if err != nil {
+		log.Fatalf("Failed to deserialize paper ledger %s: %v", b.path, err)
+	}
+	if b.ledger.Positions == nil {
+		b.ledger.Positions = map[string]*PaperPosition{}
+	}
+	if b.ledger.NextOrderID > b.nextOrderID {
+		b.nextOrderID = b.ledger.NextOrderID
+	}
+	for _, state := range b.ledger.Orders {
+		order := &paperOrder{
+			id: state.ID,
+			timestamp: state.Timestamp,
+			expiration: state.Expiration,
+			slug: state.Slug,
+			tokenID: state.TokenID,
+			bookID: state.BookID,
+			side: getModelSide(state.Side),
+			complement: state.Complement,
+			buy: state.Buy,
+			limit: state.Limit,
+			size: state.Size,
+			remaining: state.Remaining,
+			cost: state.Cost,
+			queueAhead: state.QueueAhead,
+			canceled: state.Canceled,
+		}
+		b.history[order.id] = order
+		if order.remaining.IsPositive() && !order.canceled {
+			b.orders = append(b.orders, order)
+		}
+	}
+	format := "Loaded paper ledger: cash = %s, realized P&L = %s, positions = %d, fills = %d, resting orders = %d"
+	log.Printf(format, b.ledger.Cash, b.ledger.RealizedProfit, len(b.ledger.Positions), len(b.ledger.Fills), len(b.orders))
+}
+
+func (b *paperBroker) save() {
+	b.ledger.NextOrderID = b.nextOrderID
+	b.ledger.Orders = []PaperOrderState{}
+	for _, order := range b.history {
+		state := PaperOrderState{
+			ID: order.id,
+			Timestamp: order.timestamp,
+			Expiration: order.expiration,
+			Slug: order.slug,
+			TokenID: order.tokenID,
+			BookID: order.bookID,
+			Side: getOrderSide(order.side),
+			Complement: order.complement,
+			Buy: order.buy,
+			Limit: order.limit,
+			Size: order.size,
+			Remaining: order.remaining,
+			Cost: order.cost,
+			QueueAhead: order.queueAhead,
+			Canceled: order.canceled,
+		}
+		b.ledger.Orders = append(b.ledger.Orders, state)
+	}
+	slices.SortFunc(b.ledger.Orders, func (first, second PaperOrderState) int {
+		return first.Timestamp.Compare(second.Timestamp)
+	})
+	bytes, err := json.MarshalIndent(b.ledger, "", "\t")
+	if err != nil {
+		log.Printf("Failed to serialize paper ledger: %v", err)
+		return
+	}
+	commons.WriteFile(b.path, string(bytes))
+}
+
+func (b *paperBroker) registerComplement(tokenID, bookID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.complements[tokenID] = bookID
+}
+
+func (b *paperBroker) postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limit decimal.Decimal, negRisk bool, expiration int) (string, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	bookID := tokenID
+	complementID, complement := b.complements[tokenID]
+	if complement {
+		bookID = complementID
+	}
+	book, exists := b.books[bookID]
+	if !exists {
+		return "", fmt.Errorf("no order book available for %s", slug)
+	}
+	buy := side == model.BUY
+	if complement {
+		buy = !buy
+		limit = decimal.NewFromInt(1).Sub(limit)
+	}
+	order := &paperOrder{
+		id: fmt.Sprintf("paper-%d", b.nextOrderID),
+		timestamp: getTime(),
+		slug: slug,
+		tokenID: tokenID,
+		bookID: bookID,
+		side: side,
+		complement: complement,
+		buy: buy,
+		limit: limit,
+		size: size,
+		remaining: size,
+		cost: decimal.Zero,
+		queueAhead: decimal.Zero,
+		canceled: false,
+	}
+	b.nextOrderID++
+	b.history[order.id] = order
+	if expiration > 0 {
+		expirationTime := order.timestamp.Add(time.Duration(expiration) * time.Second)
+		order.expiration = &expirationTime
+	}
+	log.Printf("Paper order %s: slug = %s, side = %s, size = %s, limit = %s", order.id, slug, getOrderSide(side), size, order.getLimit())
+	b.match(order, book, false)
+	if order.remaining.IsPositive() {
+		order.queueAhead = getLevelSize(book.getSide(order.buy), order.limit)
+		b.orders = append(b.orders, order)
+		log.Printf("Paper order %s is resting: remaining = %s, queue ahead = %s", order.id, order.remaining, order.queueAhead)
+	}
+	b.save()
+	return order.id, nil
+}
+
+func (b *paperBroker) getOrder(orderID string) (orderStatus, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	order, exists := b.history[orderID]
+	if !exists {
+		return orderStatus{}, fmt.Errorf("unknown paper order: %s", orderID)
+	}
+	state := orderOpen
+	if !order.remaining.IsPositive() {
+		state = orderFilled
+	} else if order.canceled {
+		state = orderCanceled
+	}
+	status := orderStatus{
+		state: state,
+		sizeMatched: order.size.Sub(order.remaining),
+		cost: order.cost,
+	}
+	return status, nil
+}
+
+func (b *paperBroker) cancelOrder(orderID string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	order, exists := b.history[orderID]
+	if !exists {
+		return fmt.Errorf("unknown paper order: %s", orderID)
+	}
+	if !order.remaining.IsPositive() {
+		return fmt.Errorf("paper order %s has already been filled", orderID)
+	}
+	order.canceled = true
+	b.removeCanceledOrders()
+	b.save()
+	return nil
+}
+
+func (b *paperBroker) cancelAllOrders() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, order := range b.orders {
+		order.canceled = true
+	}
+	b.removeCanceledOrders()
+	b.save()
+	return nil
+}
+
+func (b *paperBroker) removeCanceledOrders() {
+	orders := []*paperOrder{}
+	for _, order := range b.orders {
+		if order.canceled {
+			log.Printf("Paper order %s has been canceled with %s remaining", order.id, order.remaining)
+		} else {
+			orders = append(orders, order)
+		}
+	}
+	b.orders = orders
+}
+
+func (b *paperBroker) update(bookID string, bids, asks *treemap.Map, message gamma.BookMessage) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	book := b.updateBook(bookID, bids, asks, message)
+	var tradePrice, tradeSize decimal.Decimal
+	var tradeErr error
+	if message.EventType == gamma.LastTradePriceEvent {
+		tradePrice, tradeSize, tradeErr = getPriceSize(message.Price, message.Size)
+	}
+	now := getTime()
+	expired := false
+	orders := []*paperOrder{}
+	for _, order := range b.orders {
+		if order.bookID == bookID {
+			if message.EventType == gamma.LastTradePriceEvent {
+				if tradeErr == nil {
+					consumed := b.onTrade(order, tradePrice, tradeSize)
+					tradeSize = tradeSize.Sub(consumed)
+				}
+			} else {
+				b.match(order, book, true)
+				order.queueAhead = decimal.Min(order.queueAhead, getLevelSize(book.getSide(order.buy), order.limit))
+			}
+		}
+		if !order.remaining.IsPositive() {
+			log.Printf("Paper order %s has been filled", order.id)
+			continue
+		}
+		if order.expiration != nil && !now.Before(*order.expiration) {
+			log.Printf("Paper order %s has expired with %s remaining", order.id, order.remaining)
+			order.canceled = true
+			expired = true
+			continue
+		}
+		orders = append(orders, order)
+	}
+	b.orders = orders
+	if expired {
+		b.save()
+	}
+}
+
+func (b *paperBroker) updateBook(bookID string, bids, asks *treemap.Map, message gamma.BookMessage) paperBook {
+	book, exists := b.books[bookID]
+	if !exists || message.EventType == gamma.BookEvent {
+		book = paperBook{
+			bids: bids,
+			asks: asks,
+			consumedBids: treemap.NewWith(decimalComparator),
+			consumedAsks: treemap.NewWith(decimalComparator),
+		}
+	} else if message.EventType == gamma.PriceChangeEvent {
+		for _, change := range message.PriceChanges {
+			if change.AssetID != bookID {
+				continue
+			}
+			price, err := decimal.NewFromString(change.Price)
+			if err != nil {
+				continue
+			}
+			book.consumedBids.Remove(price)
+			book.consumedAsks.Remove(price)
+		}
+	}
+	b.books[bookID] = book
+	return book
+}
+
+func (b *paperBroker) match(order *paperOrder, book paperBook, resting bool) {
+	it := book.getSide(!order.buy).Iterator()
+	consumed := book.getConsumed(!order.buy)
+	next := it.Next
+	if !order.buy {
+		it.End()
+		next = it.Prev
+	}
+	for next() && order.remaining.IsPositive() {
+		price := it.Key().(decimal.Decimal)
+		size := it.Value().(decimal.Decimal)
+		if (order.buy && price.GreaterThan(order.limit)) || (!order.buy && price.LessThan(order.limit)) {
+			break
+		}
+		levelConsumed := getLevelSize(consumed, price)
+		available := size.Sub(levelConsumed)
+		if !available.IsPositive() {
+			continue
+		}
+		quantity := decimal.Min(order.remaining, available)
+		consumed.Put(price, levelConsumed.Add(quantity))
+		fillPrice := price
+		if resting {
+			fillPrice = order.limit
+		}
+		b.fill(order, fillPrice, quantity)
+	}
+}
+
+func (b *paperBroker) onTrade(order *paperOrder, price, size decimal.Decimal) decimal.Decimal {
+	if !size.IsPositive() {
+		return decimal.Zero
+	}
+	through := (order.buy && price.LessThan(order.limit)) || (!order.buy && price.GreaterThan(order.limit))
+	if through {
+		quantity := decimal.Min(order.remaining, size)
+		b.fill(order, order.limit, quantity)
+		return quantity
+	} else if price.Equal(order.limit) {
+		consumed := decimal.Min(order.queueAhead, size)
+		order.queueAhead = order.queueAhead.Sub(consumed)
+		available := size.Sub(consumed)
+		if available.IsPositive() {
+			quantity := decimal.Min(order.remaining, available)
+			b.fill(order, order.limit, quantity)
+			return quantity
+		}
+	}
+	return decimal.Zero
+}
+
+func (b *paperBroker) fill(order *paperOrder, bookPrice, quantity decimal.Decimal) {
+	price := bookPrice
+	if order.complement {
+		price = decimal.NewFromInt(1).Sub(bookPrice)
+	}
+	order.remaining = order.remaining.Sub(quantity)
+	order.cost = order.cost.Add(price.Mul(quantity))
+	position, exists := b.ledger.Positions[order.tokenID]
+	if !exists {
+		position = &PaperPosition{
+			Slug: order.slug,
+			Size: decimal.Zero,
+			Cost: decimal.Zero,
+		}
+		b.ledger.Positions[order.tokenID] = position
+	}
+	value := price.Mul(quantity)
+	if order.side == model.BUY {
+		b.ledger.Cash = b.ledger.Cash.Sub(value)
+		position.Size = position.Size.Add(quantity)
+		position.Cost = position.Cost.Add(value)
+	} else {
+		b.ledger.Cash = b.ledger.Cash.Add(value)
+		matched := decimal.Min(quantity, position.Size)
+		if matched.IsPositive() {
+			averagePrice := position.Cost.Div(position.Size)
+			profit := price.Sub(averagePrice).Mul(matched)
+			b.ledger.RealizedProfit = b.ledger.RealizedProfit.Add(profit)
+			position.Size = position.Size.Sub(matched)
+			position.Cost = position.Cost.Sub(averagePrice.Mul(matched))
+		}
+		if quantity.GreaterThan(matched) {
+			log.Printf("Warning: paper sale of %s exceeds the paper position in %s", quantity, order.slug)
+		}
+	}
+	if !position.Size.IsPositive() {
+		delete(b.ledger.Positions, order.tokenID)
+	}
+	fill := PaperFill{
+		Timestamp: getTime(),
+		Slug: order.slug,
+		TokenID: order.tokenID,
+		Side: getOrderSide(order.side),
+		Price: price,
+		Size: quantity,
+	}
+	b.ledger.Fills = append(b.ledger.Fills, fill)
+	format := "Paper fill for order %s: slug = %s, side = %s, price = %s, size = %s, cash = %s, realized P&L = %s"
+	log.Printf(format, order.id, order.slug, fill.Side, price, quantity, b.ledger.Cash, b.ledger.RealizedProfit)
+	b.save()
+}
+
+func (o *paperOrder) getLimit() decimal.Decimal {
+	if o.complement {
+		return decimal.NewFromInt(1).Sub(o.limit)
+	}
+	return o.limit
+}
+
+func (b paperBook) getSide(bids bool) *treemap.Map {
+	if bids {
+		return b.bids
+	} else {
+		return b.asks
+	}
+}
+
+func (b paperBook) getConsumed(bids bool) *treemap.Map {
+	if bids {
+		return b.consumedBids
+	} else {
+		return b.consumedAsks
+	}
+}
+
+func getLevelSize(book *treemap.Map, price decimal.Decimal) decimal.Decimal {
+	size, exists := book.Get(price)
+	if !exists {
+		return decimal.Zero
+	}
+	return size.(decimal.Decimal)
+}
\ No newline at end of file
@@ -102,7 +102,7 @@ func getAllActivities(start, end *time.Time) []gamma.Activity {
 			currentStart = *start
 		}
 		// log.Printf("Activities: currentStart = %s, currentEnd = %s", commons.GetDateString(currentStart), commons.GetDateString(currentEnd))
-		activities, err := gamma.GetActivities(configuration.Credentials.ProxyAddress, 0, currentStart, currentEnd)
+		activities, err := gamma.GetActivities(getConfiguration().Credentials.ProxyAddress, 0, currentStart, currentEnd)
 		if err != nil {
 			log.Fatalf("Failed to download activites: %v", err)
 		}
@@ -365,7 +365,7 @@ func getMatchingCategory(timestamp time.Time, slug string, categories *[]activit
 }
 
 func processPositions(categories *[]activityCategory, markets *[]activityMarket) {
-	positions, err := gamma.GetPositions(configuration.Credentials.ProxyAddress)
+	positions, err := gamma.GetPositions(getConfiguration().Credentials.ProxyAddress)
 	if err != nil {
 		log.Fatalf("Failed to get positions: %v", err)
 	}
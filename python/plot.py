@@ -8,9 +8,9 @@ import matplotlib.ticker as mticker
 import matplotlib.dates as mdates
 
 def render_heatmap():
-	x_labels, y_labels, data = get_heatmap_data()
-	width = 1.1 * len(x_labels)
-	height = 0.9 * len(y_labels)
+	heatmap, x_labels, y_labels, data = get_heatmap_data()
+	width = max(1.1 * len(x_labels), 6)
+	height = max(0.9 * len(y_labels), 4)
 	plt.figure(figsize=(width, height))
 	sns.heatmap(
 		data, 
@@ -20,9 +20,9 @@ def render_heatmap():
 		fmt=".2f",
 		cmap="viridis"
 	)
-	plt.xlabel("Range")
-	plt.ylabel("Tag")
-	plt.title("Sharpe Ratio")
+	plt.xlabel(heatmap["xLabel"])
+	plt.ylabel(heatmap["yLabel"])
+	plt.title(heatmap["title"])
 	plt.xticks(rotation=45, ha="right", fontsize=10)
 	plt.yticks(rotation=0, fontsize=10)
 	plt.tight_layout()
@@ -30,27 +30,26 @@ def render_heatmap():
 
 def get_heatmap_data():
 	data = sys.stdin.read()
-	results = json.loads(data)
+	heatmap = json.loads(data)
 	x_labels = []
 	y_labels = []
-	for result in results:
-		parameter = result["parameter"]
-		if parameter not in x_labels:
-			x_labels.append(parameter)
-		tag = result["tag"]
-		if tag not in y_labels:
-			y_labels.append(tag)
+	values = {}
+	for cell in heatmap["cells"]:
+		x = cell["x"]
+		if x not in x_labels:
+			x_labels.append(x)
+		y = cell["y"]
+		if y not in y_labels:
+			y_labels.append(y)
+		values[(x, y)] = cell["value"]
 	data = []
-	i = 0
 	for y in y_labels:
 		row = []
 		for x in x_labels:
-			result = results[i]
-			sharpe_ratio = result["sharpeRatio"]
-			row.append(sharpe_ratio)
-			i += 1
+			value = values.get((x, y), float("nan"))
+			row.append(value)
 		data.append(row)
-	return x_labels, y_labels, data
+	return heatmap, x_labels, y_labels, data
 
 def render_equity_curve():
 	x, y = get_equity_curve_data()
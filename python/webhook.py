@@ -0,0 +1,28 @@
+import json
+import sys
+
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+class WebhookHandler(BaseHTTPRequestHandler):
+	def do_POST(self):
+		length = int(self.headers.get("Content-Length", 0))
+		body = self.rfile.read(length)
+		try:
+			alert = json.loads(body)
+			print(f"[{alert['severity']}] {alert['timestamp']} {alert['slug']}: {alert['message']}")
+		except (ValueError, KeyError):
+			print(f"Invalid alert: {body}")
+		self.send_response(200)
+		self.end_headers()
+
+	def log_message(self, format, *args):
+		pass
+
+def main():
+	port = int(sys.argv[1]) if len(sys.argv) > 1 else 8080
+	server = HTTPServer(("127.0.0.1", port), WebhookHandler)
+	print(f"Listening for alerts on http://127.0.0.1:{port}")
+	server.serve_forever()
+
+if __name__ == "__main__":
+	main()
\ No newline at end of file
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"slices"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configurationPollInterval = 5
+)
+
+type configurationWatcher struct {
+	modified time.Time
+	validate func (*Configuration) error
+	onChange func (*Configuration)
+}
+
+func newConfigurationWatcher(validate func (*Configuration) error, onChange func (*Configuration)) *configurationWatcher {
+	info, err := os.Stat(configurationPath)
+	if err != nil {
+		log.Printf("Unable to watch configuration file %s: %v", configurationPath, err)
+		return nil
+	}
+	watcher := &configurationWatcher{
+		modified: info.ModTime(),
+		validate: validate,
+		onChange: onChange,
+	}
+	go watcher.run()
+	return watcher
+}
+
+func (w *configurationWatcher) run() {
+	for {
+		time.Sleep(time.Duration(configurationPollInterval) * time.Second)
+		info, err := os.Stat(configurationPath)
+		if err != nil {
+			log.Printf("Failed to check configuration file %s: %v", configurationPath, err)
+			continue
+		}
+		if info.ModTime().Equal(w.modified) {
+			continue
+		}
+		w.modified = info.ModTime()
+		next, err := w.load()
+		if err != nil {
+			log.Printf("Rejected modified configuration file: %v", err)
+			sendAlert(alertWarning, "", "Rejected modified configuration file: %v", err)
+			continue
+		}
+		log.Printf("Detected a valid change in configuration file %s", configurationPath)
+		w.onChange(next)
+	}
+}
+
+func (w *configurationWatcher) load() (*Configuration, error) {
+	bytes, err := os.ReadFile(configurationPath)
+	if err != nil {
+		return nil, err
+	}
+	next := &Configuration{}
+	err = yaml.Unmarshal(bytes, next)
+	if err != nil {
+		return nil, err
+	}
+	err = next.validate()
+	if err != nil {
+		return nil, err
+	}
+	if w.validate != nil {
+		err = w.validate(next)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return next, nil
+}
+
+func applyConfiguration(next *Configuration, orders *orderManager) {
+	previous := getConfiguration()
+	if !reflect.DeepEqual(next.Database, previous.Database) {
+		log.Printf("Warning: changes to the database configuration require a restart")
+		next.Database = previous.Database
+	}
+	if !reflect.DeepEqual(next.Paper, previous.Paper) {
+		log.Printf("Warning: changes to the paper trading configuration require a restart")
+		next.Paper = previous.Paper
+	}
+	if *next.Trigger.Live != *previous.Trigger.Live || *next.Trigger.Paper != *previous.Trigger.Paper {
+		log.Printf("Warning: switching trigger mode between live, paper and dry run trading requires a restart")
+		next.Trigger.Live = previous.Trigger.Live
+		next.Trigger.Paper = previous.Trigger.Paper
+	}
+	if !reflect.DeepEqual(next.Data.WAL, previous.Data.WAL) {
+		log.Printf("Warning: changes to the write-ahead log directory require a restart")
+		next.Data.WAL = previous.Data.WAL
+	}
+	next.Trigger.State = previous.Trigger.State
+	setConfiguration(next)
+	initializeNotifiers()
+	if orders != nil && orders.risk != nil {
+		orders.risk.setConfiguration(next.Risk)
+	}
+	log.Printf("Applied modified configuration")
+}
+
+func (s *tradingSystem) onReload(next *Configuration) {
+	if s.reloadConfiguration(next) {
+		s.resubscribe = true
+	}
+}
+
+func (s *tradingSystem) reloadConfiguration(next *Configuration) bool {
+	previous := getConfiguration()
+	applyConfiguration(next, s.orders)
+	switch s.mode {
+	case systemDataMode:
+		data := previous.Data
+		resubscribe := !slices.Equal(next.Data.TagSlugs, data.TagSlugs) || !slices.Equal(next.Data.Events, data.Events) || !next.Data.MinVolume.Equal(data.MinVolume.Decimal)
+		if resubscribe {
+			log.Printf("Data mode markets have changed, resubscribing")
+		}
+		return resubscribe
+	case systemTriggerMode:
+		return s.reloadTriggers()
+	}
+	return false
+}
+
+func (s *tradingSystem) reloadTriggers() bool {
+	resubscribe := false
+	triggers := []*triggerData{}
+	for _, trigger := range s.triggers {
+		definition, exists := getTriggerDefinition(trigger.slug)
+		if !exists {
+			log.Printf("Removed trigger \"%s\"", trigger.slug)
+			if trigger.bracketOrderID != "" {
+				s.cancelBracketOrder(trigger, false)
+			}
+			resubscribe = true
+			continue
+		}
+		if !reflect.DeepEqual(definition, trigger.trigger) {
+			if trigger.bracketOrderID != "" && (!*definition.Bracket || !definition.TakeProfitLimit.Equal(trigger.trigger.TakeProfitLimit.Decimal)) {
+				s.cancelBracketOrder(trigger, true)
+			}
+			market, _ := s.getMarketBySlug(trigger.slug)
+			trigger.trigger = definition
+			trigger.exitTime = getTriggerExitTime(definition, market)
+			log.Printf("Updated trigger \"%s\"", trigger.slug)
+		}
+		triggers = append(triggers, trigger)
+	}
+	s.triggers = triggers
+	for _, definition := range getConfiguration().Trigger.Triggers {
+		_, exists := s.getTrigger(*definition.Slug)
+		if !exists {
+			log.Printf("Added trigger \"%s\"", *definition.Slug)
+			resubscribe = true
+		}
+	}
+	if !resubscribe {
+		s.placeBracketOrders()
+	}
+	s.saveState(true)
+	return resubscribe
+}
+
+func (s *tradingSystem) cancelBracketOrder(trigger *triggerData, replace bool) {
+	trigger.replaceBracket = replace
+	err := s.orders.cancel(trigger.bracketOrderID)
+	if err != nil {
+		log.Printf("Failed to cancel bracket order %s: %v", trigger.bracketOrderID, err)
+		trigger.replaceBracket = false
+	}
+}
+
+func getTriggerDefinition(slug string) (Trigger, bool) {
+	for _, trigger := range getConfiguration().Trigger.Triggers {
+		if *trigger.Slug == slug {
+			return trigger, true
+		}
+	}
+	return Trigger{}, false
+}
+
+func (s *jumpTradingSystem) onReload(next *Configuration) {
+	if s.reloadConfiguration(next) {
+		s.resubscribe = true
+	}
+}
+
+func (s *jumpTradingSystem) reloadConfiguration(next *Configuration) bool {
+	previous := getConfiguration().Jump
+	if *next.Jump.AutoTrade != *previous.AutoTrade {
+		log.Printf("Warning: enabling or disabling automated trading in the jump system requires a restart")
+		next.Jump.AutoTrade = previous.AutoTrade
+		next.Jump.Size = previous.Size
+		next.Jump.HoldingTime = previous.HoldingTime
+		next.Jump.EntryTimeout = previous.EntryTimeout
+		next.Jump.StopLoss = previous.StopLoss
+	}
+	next.Jump.Live = previous.Live
+	next.Jump.Paper = previous.Paper
+	next.Jump.State = previous.State
+	applyConfiguration(next, s.orders)
+	s.applyConfiguration(next.Jump)
+	resubscribe := !slices.Equal(next.Jump.IncludeTags, previous.IncludeTags) || !slices.Equal(next.Jump.ExcludeTags, previous.ExcludeTags)
+	if resubscribe {
+		log.Printf("Jump tags have changed, resubscribing")
+	}
+	return resubscribe
+}
\ No newline at end of file
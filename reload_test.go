@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const reloadTestConfigurations = 100
+
+func TestReplayConfigurationReload(t *testing.T) {
+	session, database := newReplayTestSession(t, replayTrigger)
+	session.orders.enableRiskManagement(false)
+	messages := loadReplayMessages(database, getReplayMarkets(database.getMarkets()), replayTestStart, replayTestStart.Add(time.Hour))
+	alertLog := filepath.Join(t.TempDir(), "alerts.log")
+	configurations := []*Configuration{}
+	for range reloadTestConfigurations {
+		config := getReplayTestConfiguration(t)
+		config.Notifications.AlertLog = &alertLog
+		configurations = append(configurations, config)
+	}
+	done := make(chan bool)
+	var group sync.WaitGroup
+	group.Add(2)
+	go func () {
+		defer group.Done()
+		defer close(done)
+		for _, config := range configurations {
+			applyConfiguration(config, session.orders)
+		}
+	}()
+	go func () {
+		defer group.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				sendAlert(alertInfo, replayTestSlug, "Configuration reload test")
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+	for running := true; running; {
+		session.run(messages)
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+	}
+	group.Wait()
+	waitForAlerts()
+	if getConfiguration() != configurations[len(configurations) - 1] {
+		t.Errorf("expected the last configuration to have been applied")
+	}
+	orders := session.executor.orders
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 order, got %d", len(orders))
+	}
+	trigger, exists := session.trading.getTrigger(replayTestSlug)
+	if !exists || !trigger.triggered || trigger.pending {
+		t.Fatalf("expected trigger to have been filled")
+	}
+}
\ No newline at end of file
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/encratite/commons"
+	"github.com/encratite/gamma"
+	"github.com/gammazero/deque"
+	"github.com/polymarket/go-order-utils/pkg/model"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	replayTrigger = "trigger"
+	replayJump = "jump"
+	replayTriggerSize = "100"
+)
+
+type replayMessage struct {
+	timestamp time.Time
+	message gamma.BookMessage
+}
+
+type replayOrder struct {
+	timestamp time.Time
+	slug string
+	tokenID string
+	side model.Side
+	size decimal.Decimal
+	limit decimal.Decimal
+}
+
+type replayExecutor struct {
+	orders []replayOrder
+}
+
+type replayMarket struct {
+	market gamma.Market
+	assetID string
+}
+
+type replaySession struct {
+	executor *replayExecutor
+	orders *orderManager
+	trading *tradingSystem
+	jump *jumpTradingSystem
+	assetIDs map[string]bool
+}
+
+func runReplay(system string, start, end time.Time) {
+	loadConfiguration()
+	setNotifiers([]notifier{})
+	database := newStorage()
+	defer database.close()
+	markets := getReplayMarkets(database.getMarkets())
+	messages := loadReplayMessages(database, markets, start, end)
+	log.Printf("Loaded %d messages from %d recorded markets", len(messages), len(markets))
+	session := newReplaySession(system, markets, database)
+	printReplayGaps(database.getGapsInRange(start, end), session.assetIDs)
+	session.run(messages)
+	session.printSummary()
+}
+
+func newReplaySession(system string, markets []replayMarket, database storage) *replaySession {
+	executor := &replayExecutor{
+		orders: []replayOrder{},
+	}
+	session := &replaySession{
+		executor: executor,
+		orders: newOrderManager(executor),
+		trading: nil,
+		jump: nil,
+		assetIDs: map[string]bool{},
+	}
+	switch system {
+	case replayTrigger:
+		session.trading = newReplayTradingSystem(markets, database, session.orders)
+		for _, trigger := range session.trading.triggers {
+			session.assetIDs[trigger.assetID] = true
+		}
+	case replayJump:
+		session.jump = newReplayJumpSystem(markets, session.orders)
+		for _, subscription := range session.jump.subscriptions {
+			session.assetIDs[subscription.yesID] = true
+		}
+	default:
+		log.Fatalf("Unknown replay system: %s", system)
+	}
+	return session
+}
+
+func (r *replaySession) run(messages []replayMessage) {
+	for _, message := range messages {
+		if !r.assetIDs[message.message.AssetID] {
+			continue
+		}
+		simulatedTime = &message.timestamp
+		if r.trading != nil {
+			r.trading.onBookMessage(message.message)
+		} else {
+			r.jump.onBookMessage(message.message)
+		}
+		r.orders.poll()
+	}
+	simulatedTime = nil
+}
+
+func (r *replaySession) printSummary() {
+	if r.trading != nil {
+		r.trading.printReplaySummary()
+	} else {
+		r.jump.printReplaySummary()
+	}
+	r.executor.printOrders()
+}
+
+func newReplayTradingSystem(markets []replayMarket, database storage, orders *orderManager) *tradingSystem {
+	system := tradingSystem{
+		mode: systemTriggerMode,
+		markets: []gamma.Market{},
+		subscriptions: map[string]marketSubscription{},
+		database: database,
+		triggers: []*triggerData{},
+		orders: orders,
+		paper: nil,
+		state: nil,
+		restored: nil,
+		watcher: nil,
+		loop: newEventLoop(true),
+		resubscribe: false,
+		assets: map[string]bool{},
+		generation: 0,
+		dropped: map[string]bool{},
+		shards: 0,
+		gap: nil,
+		replay: true,
+	}
+	for _, market := range markets {
+		system.markets = append(system.markets, market.market)
+		system.assets[market.assetID] = true
+	}
+	for _, trigger := range getConfiguration().Trigger.Triggers {
+		slug := *trigger.Slug
+		market, exists := commons.Find(markets, func (m replayMarket) bool {
+			return m.market.Slug == slug
+		})
+		if !exists {
+			log.Printf("Warning: no recorded data for trigger slug \"%s\"", slug)
+			continue
+		}
+		data := triggerData{
+			slug: slug,
+			assetID: market.assetID,
+			size: decimalConstant(replayTriggerSize),
+			trigger: trigger,
+			triggered: false,
+			pending: false,
+			orderID: "",
+			highWater: decimal.Zero,
+			exitTime: getTriggerExitTime(trigger, nil),
+			bracketOrderID: "",
+			exitLimit: nil,
+			exitNegRisk: false,
+			replaceBracket: false,
+			bracketPending: false,
+		}
+		system.triggers = append(system.triggers, &data)
+	}
+	return &system
+}
+
+func newReplayJumpSystem(markets []replayMarket, orders *orderManager) *jumpTradingSystem {
+	validateJumpConfiguration()
+	system := newJumpTradingSystem(orders, newEventLoop(true))
+	for _, market := range markets {
+		if market.market.NegRisk {
+			continue
+		}
+		system.subscriptions[market.market.ConditionID] = jumpSubscription{
+			market: market.market,
+			yesID: market.assetID,
+			noID: "",
+			prices: deque.Deque[jumpPriceEvent]{},
+			triggered: false,
+			spread: nil,
+			bestBid: nil,
+			bestAsk: nil,
+			bids: treemap.NewWith(decimalComparator),
+			asks: treemap.NewWith(decimalComparator),
+			position: nil,
+			integrity: bookIntegrity{},
+		}
+	}
+	return system
+}
+
+func printReplayGaps(gaps []CollectionGap, assetIDs map[string]bool) {
+	for _, gap := range gaps {
+		affected := commons.ContainsFunc(gap.AssetIDs, func (assetID string) bool {
+			return assetIDs[assetID]
+		})
+		if !affected {
+			continue
+		}
+		reconnected := "never"
+		if gap.Reconnected != nil {
+			reconnected = gap.Reconnected.Format(time.DateTime)
+		}
+		log.Printf("Warning: data is missing from %s to %s (%s)", gap.Disconnected.Format(time.DateTime), reconnected, gap.Reason)
+	}
+}
+
+func getReplayMarkets(recordedMarkets []MarketBSON) []replayMarket {
+	markets := []replayMarket{}
+	for _, recordedMarket := range recordedMarkets {
+		conditionID := recordedMarket.ConditionID
+		if conditionID == "" {
+			conditionID = recordedMarket.AssetID
+		}
+		exists := commons.ContainsFunc(markets, func (m replayMarket) bool {
+			return m.market.ConditionID == conditionID
+		})
+		if exists {
+			continue
+		}
+		market := replayMarket{
+			market: gamma.Market{
+				Slug: recordedMarket.Slug,
+				ConditionID: conditionID,
+				NegRisk: recordedMarket.NegRisk,
+			},
+			assetID: recordedMarket.AssetID,
+		}
+		markets = append(markets, market)
+	}
+	return markets
+}
+
+func loadReplayMessages(database storage, markets []replayMarket, start, end time.Time) []replayMessage {
+	conditionIDs := map[string]string{}
+	for _, market := range markets {
+		conditionIDs[market.assetID] = market.market.ConditionID
+	}
+	messages := []replayMessage{}
+	add := func (timestamp time.Time, message gamma.BookMessage) {
+		message.Market = conditionIDs[message.AssetID]
+		message.Timestamp = commons.Int64ToString(timestamp.UnixMilli())
+		replayMessage := replayMessage{
+			timestamp: timestamp,
+			message: message,
+		}
+		messages = append(messages, replayMessage)
+	}
+	for _, bookEvent := range database.getBookEventsInRange(start, end) {
+		message := gamma.BookMessage{
+			EventType: gamma.BookEvent,
+			AssetID: bookEvent.AssetID,
+			Bids: getOrderSummaries(bookEvent.Bids),
+			Asks: getOrderSummaries(bookEvent.Asks),
+		}
+		add(bookEvent.ServerTime, message)
+	}
+	for _, priceChange := range database.getPriceChangesInRange(start, end) {
+		change := gamma.PriceChange{
+			AssetID: priceChange.AssetID,
+			Price: priceChange.Price.String(),
+			Size: priceChange.Size.String(),
+			Side: getBookSide(priceChange.Buy),
+			BestBid: priceChange.BestBid.String(),
+			BestAsk: priceChange.BestAsk.String(),
+		}
+		message := gamma.BookMessage{
+			EventType: gamma.PriceChangeEvent,
+			AssetID: priceChange.AssetID,
+			PriceChanges: []gamma.PriceChange{
+				change,
+			},
+		}
+		add(priceChange.ServerTime, message)
+	}
+	for _, lastTradePrice := range database.getLastTradePricesInRange(start, end) {
+		message := gamma.BookMessage{
+			EventType: gamma.LastTradePriceEvent,
+			AssetID: lastTradePrice.AssetID,
+			Price: lastTradePrice.Price.String(),
+			Size: lastTradePrice.Size.String(),
+			Side: getBookSide(lastTradePrice.Buy),
+		}
+		add(lastTradePrice.ServerTime, message)
+	}
+	slices.SortStableFunc(messages, func (a, b replayMessage) int {
+		return a.timestamp.Compare(b.timestamp)
+	})
+	return messages
+}
+
+func (e *replayExecutor) postOrder(slug, tokenID string, side model.Side, size decimal.Decimal, limit decimal.Decimal, negRisk bool, expiration int) (string, error) {
+	order := replayOrder{
+		timestamp: getTime(),
+		slug: slug,
+		tokenID: tokenID,
+		side: side,
+		size: size,
+		limit: limit,
+	}
+	log.Printf("Replay order: slug = %s, side = %s, size = %s, limit = %s", slug, getOrderSide(side), size, limit)
+	e.orders = append(e.orders, order)
+	orderID := commons.IntToString(len(e.orders))
+	return orderID, nil
+}
+
+func (e *replayExecutor) getOrder(orderID string) (orderStatus, error) {
+	index := commons.MustParseInt(orderID) - 1
+	if index < 0 || index >= len(e.orders) {
+		return orderStatus{}, fmt.Errorf("unknown replay order: %s", orderID)
+	}
+	status := orderStatus{
+		state: orderFilled,
+		sizeMatched: e.orders[index].size,
+		cost: e.orders[index].size.Mul(e.orders[index].limit),
+	}
+	return status, nil
+}
+
+func (e *replayExecutor) cancelOrder(orderID string) error {
+	return fmt.Errorf("replay order %s has already been filled", orderID)
+}
+
+func (e *replayExecutor) cancelAllOrders() error {
+	return nil
+}
+
+func (e *replayExecutor) printOrders() {
+	fmt.Printf("\nOrders posted during replay: %d\n", len(e.orders))
+	for _, order := range e.orders {
+		fmt.Printf("\t%s %s %s: size = %s, limit = %s\n", commons.GetTimeString(order.timestamp), getOrderSide(order.side), order.slug, order.size, order.limit)
+	}
+}
+
+func (s *tradingSystem) printReplaySummary() {
+	fmt.Printf("\nTriggers:\n")
+	for _, trigger := range s.triggers {
+		fmt.Printf("\t%s: triggered = %t\n", trigger.slug, trigger.triggered)
+	}
+	fmt.Printf("\nOrder books:\n")
+	for _, subscription := range s.subscriptions {
+		valid := subscription.validateOrderBook()
+		integrity := subscription.integrity
+		format := "\t%s: bids = %d, asks = %d, valid = %t, stale = %t, divergences = %d, crossed = %d\n"
+		fmt.Printf(format, subscription.slug, subscription.bids.Size(), subscription.asks.Size(), valid, integrity.stale, integrity.divergences, integrity.crossed)
+	}
+}
+
+func (s *jumpTradingSystem) printReplaySummary() {
+	fmt.Printf("\nTriggered markets:\n")
+	divergences := 0
+	crossed := 0
+	for _, subscription := range s.subscriptions {
+		if subscription.triggered {
+			fmt.Printf("\t%s\n", subscription.market.Slug)
+		}
+		divergences += subscription.integrity.divergences
+		crossed += subscription.integrity.crossed
+	}
+	fmt.Printf("\nOrder book divergences: %d, crossed books: %d\n", divergences, crossed)
+}
+
+func getOrderSummaries(levels []PriceLevel) []gamma.OrderSummary {
+	summaries := []gamma.OrderSummary{}
+	for _, level := range levels {
+		summary := gamma.OrderSummary{
+			Price: level.Price.String(),
+			Size: level.Size.String(),
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+func getBookSide(buy bool) string {
+	if buy {
+		return sideBuy
+	} else {
+		return sideSell
+	}
+}
+
+func getOrderSide(side model.Side) string {
+	return getBookSide(side == model.BUY)
+}
+
+func getModelSide(side string) model.Side {
+	if side == sideSell {
+		return model.SELL
+	} else {
+		return model.BUY
+	}
+}
\ No newline at end of file
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emirpasic/gods/maps/treemap"
+	"github.com/encratite/commons"
+	"github.com/encratite/gamma"
+	"github.com/gammazero/deque"
+	"github.com/polymarket/go-order-utils/pkg/model"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	replayTestSlug = "replay-test-market"
+	replayTestConditionID = "0xreplaytestcondition"
+	replayTestAssetID = "1001"
+	replayTestComplementAssetID = "1002"
+	replayTestOtherSlug = "replay-test-other-market"
+	replayTestOtherConditionID = "0xreplaytestother"
+	replayTestOtherAssetID = "2002"
+)
+
+const replayTestConfiguration = `
+data:
+  tagSlugs: [replay]
+  minVolume: 1000
+  bufferTimeSpan: 3600
+database:
+  backend: file
+  path: replay
+trigger:
+  live: false
+  recordData: false
+  triggers:
+    - slug: replay-test-market
+      stopLoss: 0.40
+      stopLossLimit: 0.39
+jump:
+  threshold1: 0.10
+  threshold2: 0.25
+  threshold3: 0.50
+  spreadLimit: 0.05
+notifications:
+  beep: false
+`
+
+var replayTestStart = time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+
+func TestReplayTriggerStopLoss(t *testing.T) {
+	session, database := newReplayTestSession(t, replayTrigger)
+	messages := loadReplayMessages(database, getReplayMarkets(database.getMarkets()), replayTestStart, replayTestStart.Add(time.Hour))
+	if len(messages) != 8 {
+		t.Fatalf("expected 8 recorded messages, got %d", len(messages))
+	}
+	session.run(messages)
+	orders := session.executor.orders
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 order, got %d", len(orders))
+	}
+	order := orders[0]
+	if order.slug != replayTestSlug || order.tokenID != replayTestAssetID || order.side != model.SELL {
+		t.Errorf("unexpected order: slug = %s, tokenID = %s, side = %d", order.slug, order.tokenID, order.side)
+	}
+	if !order.size.Equal(decimalConstant(replayTriggerSize)) || !order.limit.Equal(decimalConstant("0.39")) {
+		t.Errorf("unexpected order size %s and limit %s", order.size, order.limit)
+	}
+	expectedTime := replayTestStart.Add(4 * time.Second)
+	if !order.timestamp.Equal(expectedTime) {
+		t.Errorf("expected order at %s, got %s", expectedTime, order.timestamp)
+	}
+	trigger, exists := session.trading.getTrigger(replayTestSlug)
+	if !exists || !trigger.triggered || trigger.pending {
+		t.Fatalf("expected trigger to have been filled")
+	}
+}
+
+func TestReplayTriggerOrderBook(t *testing.T) {
+	session, database := newReplayTestSession(t, replayTrigger)
+	messages := loadReplayMessages(database, getReplayMarkets(database.getMarkets()), replayTestStart, replayTestStart.Add(time.Hour))
+	session.run(messages)
+	subscription, exists := session.trading.subscriptions[replayTestConditionID]
+	if !exists {
+		t.Fatalf("missing subscription for %s", replayTestConditionID)
+	}
+	if subscription.assetID != replayTestAssetID {
+		t.Errorf("expected asset ID %s, got %s", replayTestAssetID, subscription.assetID)
+	}
+	integrity := subscription.integrity
+	if integrity.stale || integrity.divergences != 0 || integrity.crossed != 0 {
+		t.Errorf("unexpected book integrity: stale = %t, divergences = %d, crossed = %d", integrity.stale, integrity.divergences, integrity.crossed)
+	}
+	bestBid, bestAsk := getTopOfBook(subscription.bids, subscription.asks)
+	if !bestBid.Equal(decimalConstant("0.44")) || !bestAsk.Equal(decimalConstant("0.46")) {
+		t.Errorf("unexpected top of book %s/%s", bestBid, bestAsk)
+	}
+	if subscription.bids.Size() != 2 || subscription.asks.Size() != 2 {
+		t.Errorf("unexpected book depth: bids = %d, asks = %d", subscription.bids.Size(), subscription.asks.Size())
+	}
+	_, exists = session.trading.subscriptions[replayTestOtherConditionID]
+	if exists {
+		t.Errorf("messages of %s should not have been replayed", replayTestOtherSlug)
+	}
+}
+
+func TestReplayComplementMessages(t *testing.T) {
+	session, database := newReplayTestSession(t, replayTrigger)
+	messages := loadReplayMessages(database, getReplayMarkets(database.getMarkets()), replayTestStart, replayTestStart.Add(time.Hour))
+	session.run(messages)
+	complementMessages := []gamma.BookMessage{
+		{
+			EventType: gamma.PriceChangeEvent,
+			Market: replayTestConditionID,
+			PriceChanges: []gamma.PriceChange{
+				{AssetID: replayTestComplementAssetID, Price: "0.55", Size: "500", Side: sideBuy, BestBid: "0.55", BestAsk: "0.56"},
+				{AssetID: replayTestComplementAssetID, Price: "0.56", Size: "0", Side: sideSell, BestBid: "0.55", BestAsk: "0.57"},
+			},
+		},
+		{
+			EventType: gamma.BookEvent,
+			AssetID: replayTestComplementAssetID,
+			Market: replayTestConditionID,
+			Bids: []gamma.OrderSummary{
+				{Price: "0.55", Size: "500"},
+			},
+			Asks: []gamma.OrderSummary{
+				{Price: "0.57", Size: "100"},
+			},
+		},
+	}
+	for _, message := range complementMessages {
+		session.trading.onBookMessage(message)
+	}
+	subscription := session.trading.subscriptions[replayTestConditionID]
+	if subscription.integrity.stale || subscription.integrity.divergences != 0 {
+		t.Errorf("complement messages should not affect the integrity of the order book")
+	}
+	bestBid, bestAsk := getTopOfBook(subscription.bids, subscription.asks)
+	if !bestBid.Equal(decimalConstant("0.44")) || !bestAsk.Equal(decimalConstant("0.46")) {
+		t.Errorf("unexpected top of book %s/%s", bestBid, bestAsk)
+	}
+	if subscription.bids.Size() != 2 || subscription.asks.Size() != 2 {
+		t.Errorf("unexpected book depth: bids = %d, asks = %d", subscription.bids.Size(), subscription.asks.Size())
+	}
+}
+
+func TestReplayJumpTrigger(t *testing.T) {
+	session, database := newReplayTestSession(t, replayJump)
+	messages := loadReplayMessages(database, getReplayMarkets(database.getMarkets()), replayTestStart, replayTestStart.Add(time.Hour))
+	session.run(messages)
+	subscription, exists := session.jump.subscriptions[replayTestOtherConditionID]
+	if !exists {
+		t.Fatalf("missing jump subscription for %s", replayTestOtherConditionID)
+	}
+	if !subscription.triggered {
+		t.Errorf("expected jump in %s to have been triggered", replayTestOtherSlug)
+	}
+	subscription = session.jump.subscriptions[replayTestConditionID]
+	if subscription.triggered {
+		t.Errorf("jump in %s should not have been triggered", replayTestSlug)
+	}
+	if len(session.executor.orders) != 0 {
+		t.Errorf("expected no orders without automated trading, got %d", len(session.executor.orders))
+	}
+}
+
+func TestGetReplayMarkets(t *testing.T) {
+	recordedMarkets := []MarketBSON{
+		{
+			Slug: replayTestSlug,
+			AssetID: replayTestAssetID,
+			ConditionID: replayTestConditionID,
+		},
+		{
+			Slug: replayTestSlug,
+			AssetID: replayTestAssetID,
+			ConditionID: replayTestConditionID,
+		},
+		{
+			Slug: replayTestOtherSlug,
+			AssetID: replayTestOtherAssetID,
+			ConditionID: "",
+		},
+	}
+	markets := getReplayMarkets(recordedMarkets)
+	if len(markets) != 2 {
+		t.Fatalf("expected 2 markets, got %d", len(markets))
+	}
+	if markets[0].market.ConditionID != replayTestConditionID || markets[0].assetID != replayTestAssetID {
+		t.Errorf("unexpected market: conditionID = %s, assetID = %s", markets[0].market.ConditionID, markets[0].assetID)
+	}
+	if markets[1].market.ConditionID != replayTestOtherAssetID {
+		t.Errorf("expected recordings without a condition ID to fall back to the asset ID, got %s", markets[1].market.ConditionID)
+	}
+}
+
+func newReplayTestSession(t *testing.T, system string) (*replaySession, storage) {
+	loadReplayTestConfiguration(t)
+	database := newFileStorage(t.TempDir())
+	markets := []gamma.Market{
+		{
+			Slug: replayTestSlug,
+			ConditionID: replayTestConditionID,
+		},
+		{
+			Slug: replayTestOtherSlug,
+			ConditionID: replayTestOtherConditionID,
+		},
+	}
+	assetIDs := []string{
+		replayTestAssetID,
+		replayTestOtherAssetID,
+	}
+	eventSlugMap := map[string]string{
+		replayTestSlug: "replay-test-event",
+		replayTestOtherSlug: "replay-test-event",
+	}
+	database.insertMarkets(markets, assetIDs, eventSlugMap)
+	subscription := marketSubscription{
+		prices: deque.Deque[priceEvent]{},
+		bids: treemap.NewWith(decimalComparator),
+		asks: treemap.NewWith(decimalComparator),
+	}
+	for _, message := range getReplayTestMessages() {
+		database.insertBookMessage(message, subscription)
+	}
+	database.flushBuffer()
+	session := newReplaySession(system, getReplayMarkets(database.getMarkets()), database)
+	return session, database
+}
+
+func loadReplayTestConfiguration(t *testing.T) {
+	setConfiguration(getReplayTestConfiguration(t))
+	setNotifiers([]notifier{})
+}
+
+func getReplayTestConfiguration(t *testing.T) *Configuration {
+	config := &Configuration{}
+	err := yaml.Unmarshal([]byte(replayTestConfiguration), config)
+	if err != nil {
+		t.Fatalf("Failed to parse configuration: %v", err)
+	}
+	err = config.validate()
+	if err != nil {
+		t.Fatalf("Invalid configuration: %v", err)
+	}
+	return config
+}
+
+func getReplayTestMessages() []gamma.BookMessage {
+	timestamp := func (seconds int) string {
+		return commons.Int64ToString(replayTestStart.Add(time.Duration(seconds) * time.Second).UnixMilli())
+	}
+	messages := []gamma.BookMessage{
+		{
+			EventType: gamma.BookEvent,
+			AssetID: replayTestAssetID,
+			Timestamp: timestamp(0),
+			Bids: []gamma.OrderSummary{
+				{Price: "0.43", Size: "200"},
+				{Price: "0.44", Size: "100"},
+			},
+			Asks: []gamma.OrderSummary{
+				{Price: "0.47", Size: "50"},
+				{Price: "0.46", Size: "100"},
+			},
+		},
+		{
+			EventType: gamma.PriceChangeEvent,
+			AssetID: replayTestAssetID,
+			Timestamp: timestamp(1),
+			PriceChanges: []gamma.PriceChange{
+				{AssetID: replayTestAssetID, Price: "0.45", Size: "30", Side: sideBuy, BestBid: "0.45", BestAsk: "0.46"},
+			},
+		},
+		{
+			EventType: gamma.LastTradePriceEvent,
+			AssetID: replayTestAssetID,
+			Timestamp: timestamp(2),
+			Price: "0.46",
+			Size: "10",
+			Side: sideBuy,
+		},
+		{
+			EventType: gamma.PriceChangeEvent,
+			AssetID: replayTestAssetID,
+			Timestamp: timestamp(3),
+			PriceChanges: []gamma.PriceChange{
+				{AssetID: replayTestAssetID, Price: "0.45", Size: "0", Side: sideBuy, BestBid: "0.44", BestAsk: "0.46"},
+			},
+		},
+		{
+			EventType: gamma.LastTradePriceEvent,
+			AssetID: replayTestAssetID,
+			Timestamp: timestamp(4),
+			Price: "0.40",
+			Size: "25",
+			Side: sideSell,
+		},
+		{
+			EventType: gamma.LastTradePriceEvent,
+			AssetID: replayTestOtherAssetID,
+			Timestamp: timestamp(5),
+			Price: "0.05",
+			Size: "100",
+			Side: sideSell,
+		},
+		{
+			EventType: gamma.PriceChangeEvent,
+			AssetID: replayTestOtherAssetID,
+			Timestamp: timestamp(6),
+			PriceChanges: []gamma.PriceChange{
+				{AssetID: replayTestOtherAssetID, Price: "0.29", Size: "40", Side: sideBuy, BestBid: "0.29", BestAsk: "0.31"},
+			},
+		},
+		{
+			EventType: gamma.LastTradePriceEvent,
+			AssetID: replayTestOtherAssetID,
+			Timestamp: timestamp(7),
+			Price: "0.30",
+			Size: "60",
+			Side: sideBuy,
+		},
+	}
+	return messages
+}
\ No newline at end of file
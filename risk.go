@@ -0,0 +1,361 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/encratite/gamma"
+	"github.com/polymarket/go-order-utils/pkg/model"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	riskBreachReject = "reject"
+	riskBreachFreeze = "freeze"
+	riskBreachFlatten = "flatten"
+	flattenLimit = "0.01"
+)
+
+type riskManager struct {
+	mutex sync.Mutex
+	config RiskConfiguration
+	positions map[string]*riskPosition
+	markets map[string]riskMarket
+	dailyProfit decimal.Decimal
+	day time.Time
+	frozen bool
+	flatten func ()
+}
+
+type riskPosition struct {
+	slug string
+	tokenID string
+	size decimal.Decimal
+	cost decimal.Decimal
+}
+
+type riskMarket struct {
+	tags []string
+	negRisk bool
+}
+
+type riskFlattenOrder struct {
+	position riskPosition
+	negRisk bool
+}
+
+func newRiskManager(live bool) *riskManager {
+	risk := &riskManager{
+		config: getConfiguration().Risk,
+		positions: map[string]*riskPosition{},
+		markets: map[string]riskMarket{},
+		dailyProfit: decimal.Zero,
+		day: getRiskDay(),
+		frozen: false,
+		flatten: nil,
+	}
+	if live {
+		risk.loadPositions()
+	}
+	return risk
+}
+
+func (r *riskManager) setConfiguration(config RiskConfiguration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.config = config
+}
+
+func (r *riskManager) getConfig() RiskConfiguration {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.config
+}
+
+func (r *riskManager) loadPositions() {
+	positions, err := gamma.GetPositions(getConfiguration().Credentials.ProxyAddress)
+	if err != nil {
+		log.Fatalf("Failed to load positions for the risk manager: %v", err)
+	}
+	for _, position := range positions {
+		size := decimal.NewFromFloat(position.Size)
+		if !size.IsPositive() {
+			continue
+		}
+		r.positions[position.Asset] = &riskPosition{
+			slug: position.Slug,
+			tokenID: position.Asset,
+			size: size,
+			cost: decimal.NewFromFloat(position.AvgPrice).Mul(size),
+		}
+		if r.getConfig().MaxTagExposure != nil {
+			_, err := r.getMarket(position.Slug)
+			if err != nil {
+				log.Printf("Failed to determine the tags of %s: %v", position.Slug, err)
+			}
+		}
+	}
+	log.Printf("Risk manager loaded %d positions", len(r.positions))
+}
+
+func (r *riskManager) check(slug, tokenID string, side model.Side, size, limit decimal.Decimal) error {
+	if r.isKillSwitchActive() {
+		r.mutex.Lock()
+		r.frozen = true
+		r.mutex.Unlock()
+	}
+	if side == model.SELL {
+		return nil
+	}
+	var tags []string
+	if r.getConfig().MaxTagExposure != nil {
+		market, err := r.getMarket(slug)
+		if err != nil {
+			return fmt.Errorf("failed to determine the tags of %s: %v", slug, err)
+		}
+		tags = market.tags
+	}
+	notional := size.Mul(limit)
+	r.mutex.Lock()
+	r.resetDailyProfit()
+	if r.frozen {
+		r.mutex.Unlock()
+		return fmt.Errorf("trading is frozen, rejected buy order for %s", slug)
+	}
+	breach := r.getBreach(slug, tokenID, tags, notional)
+	r.mutex.Unlock()
+	if breach != nil {
+		r.onBreach(slug, breach)
+		return breach
+	}
+	return nil
+}
+
+func (r *riskManager) getBreach(slug, tokenID string, tags []string, notional decimal.Decimal) error {
+	config := r.config
+	if config.MaxOrderNotional != nil && notional.GreaterThan(config.MaxOrderNotional.Decimal) {
+		return fmt.Errorf("order notional %s exceeds the limit of %s", notional.StringFixed(2), config.MaxOrderNotional)
+	}
+	if config.MaxMarketPosition != nil {
+		exposure := notional.Add(r.getMarketExposure(slug, tokenID))
+		if exposure.GreaterThan(config.MaxMarketPosition.Decimal) {
+			return fmt.Errorf("position of %s in %s would exceed the limit of %s", exposure.StringFixed(2), slug, config.MaxMarketPosition)
+		}
+	}
+	if config.MaxTagExposure != nil {
+		for _, tag := range tags {
+			exposure := notional
+			for _, position := range r.positions {
+				market, exists := r.markets[position.slug]
+				if exists && commons.Contains(market.tags, tag) {
+					exposure = exposure.Add(position.cost)
+				}
+			}
+			if exposure.GreaterThan(config.MaxTagExposure.Decimal) {
+				return fmt.Errorf("exposure of %s to tag \"%s\" would exceed the limit of %s", exposure.StringFixed(2), tag, config.MaxTagExposure)
+			}
+		}
+	}
+	if config.MaxDailyLoss != nil && r.dailyProfit.Neg().GreaterThanOrEqual(config.MaxDailyLoss.Decimal) {
+		return fmt.Errorf("daily realized loss of %s has reached the limit of %s", r.dailyProfit.Neg().StringFixed(2), config.MaxDailyLoss)
+	}
+	return nil
+}
+
+func (r *riskManager) getMarketExposure(slug, tokenID string) decimal.Decimal {
+	position, exists := r.positions[tokenID]
+	if exists {
+		slug = position.slug
+	}
+	exposure := decimal.Zero
+	for _, position := range r.positions {
+		if position.slug == slug || position.tokenID == tokenID {
+			exposure = exposure.Add(position.cost)
+		}
+	}
+	return exposure
+}
+
+func (r *riskManager) onFill(order managedOrder, quantity, price decimal.Decimal) {
+	if order.tokenID == "" || !quantity.IsPositive() {
+		return
+	}
+	if r.getConfig().MaxTagExposure != nil && order.side == model.BUY {
+		_, _ = r.getMarket(order.slug)
+	}
+	r.mutex.Lock()
+	r.resetDailyProfit()
+	position, exists := r.positions[order.tokenID]
+	if !exists {
+		position = &riskPosition{
+			slug: order.slug,
+			tokenID: order.tokenID,
+			size: decimal.Zero,
+			cost: decimal.Zero,
+		}
+		r.positions[order.tokenID] = position
+	}
+	if order.side == model.BUY {
+		position.size = position.size.Add(quantity)
+		position.cost = position.cost.Add(quantity.Mul(price))
+	} else {
+		matched := decimal.Min(quantity, position.size)
+		if matched.IsPositive() {
+			averagePrice := position.cost.Div(position.size)
+			profit := price.Sub(averagePrice).Mul(matched)
+			r.dailyProfit = r.dailyProfit.Add(profit)
+			position.size = position.size.Sub(matched)
+			position.cost = position.cost.Sub(averagePrice.Mul(matched))
+		}
+	}
+	if !position.size.IsPositive() {
+		delete(r.positions, order.tokenID)
+	}
+	var breach error
+	maxDailyLoss := r.config.MaxDailyLoss
+	if !r.frozen && maxDailyLoss != nil && r.dailyProfit.Neg().GreaterThanOrEqual(maxDailyLoss.Decimal) {
+		breach = fmt.Errorf("daily realized loss of %s has reached the limit of %s", r.dailyProfit.Neg().StringFixed(2), maxDailyLoss)
+	}
+	r.mutex.Unlock()
+	if breach != nil {
+		r.onBreach(order.slug, breach)
+	}
+}
+
+func (r *riskManager) onBreach(slug string, breach error) {
+	action := *r.getConfig().Breach
+	log.Printf("Risk limit breached for %s: %v (action: %s)", slug, breach, action)
+	sendAlert(alertCritical, slug, "Risk limit breached: %v (action: %s)", breach, action)
+	if action == riskBreachReject {
+		return
+	}
+	r.mutex.Lock()
+	frozen := r.frozen
+	r.frozen = true
+	r.mutex.Unlock()
+	if frozen {
+		return
+	}
+	log.Printf("Trading has been frozen by the risk manager")
+	if action == riskBreachFlatten && r.flatten != nil {
+		go r.flatten()
+	}
+}
+
+func (r *riskManager) getFlattenOrders() []riskFlattenOrder {
+	r.mutex.Lock()
+	positions := []riskPosition{}
+	for _, position := range r.positions {
+		positions = append(positions, *position)
+	}
+	r.mutex.Unlock()
+	orders := []riskFlattenOrder{}
+	for _, position := range positions {
+		market, err := r.getMarket(position.slug)
+		if err != nil {
+			log.Printf("Unable to flatten position in %s: %v", position.slug, err)
+			continue
+		}
+		order := riskFlattenOrder{
+			position: position,
+			negRisk: market.negRisk,
+		}
+		orders = append(orders, order)
+	}
+	return orders
+}
+
+func (r *riskManager) getMarket(slug string) (riskMarket, error) {
+	r.mutex.Lock()
+	market, exists := r.markets[slug]
+	r.mutex.Unlock()
+	if exists {
+		return market, nil
+	}
+	marketData, err := gamma.GetMarket(slug)
+	if err != nil {
+		return riskMarket{}, err
+	}
+	market = riskMarket{
+		tags: []string{},
+		negRisk: marketData.NegRisk,
+	}
+	for _, event := range marketData.Events {
+		id, err := strconv.Atoi(event.ID)
+		if err != nil {
+			return riskMarket{}, err
+		}
+		tags, err := gamma.GetEventTags(id)
+		if err != nil {
+			return riskMarket{}, err
+		}
+		for _, tag := range tags {
+			if !commons.Contains(market.tags, tag.Slug) {
+				market.tags = append(market.tags, tag.Slug)
+			}
+		}
+	}
+	r.mutex.Lock()
+	r.markets[slug] = market
+	r.mutex.Unlock()
+	return market, nil
+}
+
+func (r *riskManager) isKillSwitchActive() bool {
+	killSwitch := r.getConfig().KillSwitch
+	if killSwitch == nil || !commons.FileExists(*killSwitch) {
+		return false
+	}
+	r.mutex.Lock()
+	frozen := r.frozen
+	r.mutex.Unlock()
+	if !frozen {
+		log.Printf("Kill switch %s is active, trading has been frozen", *killSwitch)
+		sendAlert(alertCritical, "", "Kill switch %s is active, trading has been frozen", *killSwitch)
+	}
+	return true
+}
+
+func (r *riskManager) isFrozen() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.frozen
+}
+
+func (r *riskManager) getState() *RiskState {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resetDailyProfit()
+	return &RiskState{
+		Day: r.day,
+		DailyProfit: r.dailyProfit,
+	}
+}
+
+func (r *riskManager) restore(state RiskState) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resetDailyProfit()
+	if !state.Day.Equal(r.day) {
+		return
+	}
+	r.dailyProfit = state.DailyProfit
+	log.Printf("Restored daily realized P&L of %s", r.dailyProfit.StringFixed(2))
+}
+
+func (r *riskManager) resetDailyProfit() {
+	day := getRiskDay()
+	if day.After(r.day) {
+		r.day = day
+		r.dailyProfit = decimal.Zero
+	}
+}
+
+func getRiskDay() time.Time {
+	now := getTime().UTC()
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
\ No newline at end of file
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/encratite/commons"
+)
+
+const (
+	downloadWorkers = 4
+	downloadRequestsPerSecond = 5.0
+	downloadRetries = 5
+	downloadCursor = "history-cursor.json"
+)
+
+type downloadScheduler struct {
+	config DownloadConfiguration
+	limiter *time.Ticker
+	slots chan struct{}
+	group sync.WaitGroup
+	mutex sync.Mutex
+	succeeded int
+	skipped int
+	failures []downloadFailure
+}
+
+type downloadFailure struct {
+	key string
+	err error
+}
+
+type downloadCursorState struct {
+	Tag string `json:"tag"`
+	Offset int `json:"offset"`
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func newPermanentError(format string, args ...any) error {
+	return permanentError{
+		err: fmt.Errorf(format, args...),
+	}
+}
+
+func newDownloadScheduler() *downloadScheduler {
+	config := DownloadConfiguration{}
+	if getConfiguration() != nil {
+		config = getConfiguration().Download
+	} else {
+		_ = config.validate()
+	}
+	interval := time.Duration(float64(time.Second) / *config.RequestsPerSecond)
+	s := &downloadScheduler{
+		config: config,
+		limiter: time.NewTicker(interval),
+		slots: make(chan struct{}, *config.Workers),
+		succeeded: 0,
+		skipped: 0,
+		failures: []downloadFailure{},
+	}
+	return s
+}
+
+func (s *downloadScheduler) submit(key string, task func () (bool, error)) {
+	s.group.Add(1)
+	go func () {
+		defer s.group.Done()
+		s.slots <- struct{}{}
+		defer func () {
+			<-s.slots
+		}()
+		var skipped bool
+		err := s.retry(key, func () error {
+			var err error
+			skipped, err = task()
+			return err
+		})
+		if err != nil {
+			s.fail(key, err)
+			return
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if skipped {
+			s.skipped++
+		} else {
+			s.succeeded++
+		}
+	}()
+}
+
+func (s *downloadScheduler) fail(key string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	failure := downloadFailure{
+		key: key,
+		err: err,
+	}
+	s.failures = append(s.failures, failure)
+}
+
+func (s *downloadScheduler) retry(key string, operation func () error) error {
+	for attempt := 0;; attempt++ {
+		err := operation()
+		if err == nil {
+			return nil
+		}
+		var permanent permanentError
+		if errors.As(err, &permanent) {
+			log.Printf("Failed to download %s: %v", key, err)
+			return err
+		}
+		if attempt >= *s.config.Retries {
+			log.Printf("Giving up on %s after %d attempts: %v", key, attempt + 1, err)
+			return err
+		}
+		delay := getBackoffDelay(attempt)
+		log.Printf("Failed to download %s, retrying in %.1f s: %v", key, delay.Seconds(), err)
+		time.Sleep(delay)
+	}
+}
+
+func (s *downloadScheduler) request(key string, operation func () error) error {
+	return s.retry(key, func () error {
+		s.throttle()
+		return operation()
+	})
+}
+
+func (s *downloadScheduler) throttle() {
+	<-s.limiter.C
+}
+
+func (s *downloadScheduler) wait() {
+	s.group.Wait()
+}
+
+func (s *downloadScheduler) printSummary() {
+	s.wait()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	log.Printf("Download summary: %d succeeded, %d skipped, %d failed", s.succeeded, s.skipped, len(s.failures))
+	for _, failure := range s.failures {
+		log.Printf("Failed: %s (%v)", failure.key, failure.err)
+	}
+}
+
+func (s *downloadScheduler) loadCursor() downloadCursorState {
+	path := *s.config.Cursor
+	var cursor downloadCursorState
+	if !commons.FileExists(path) {
+		return cursor
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatalf("Failed to read download cursor %s: %v", path, err)
+	}
+	err = json.Unmarshal(bytes, &cursor)
+	if err != nil {
+		log.Fatalf("Failed to deserialize download cursor %s: %v", path, err)
+	}
+	log.Printf("Resuming download at offset %d", cursor.Offset)
+	return cursor
+}
+
+func (s *downloadScheduler) saveCursor(cursor downloadCursorState) {
+	bytes, err := json.Marshal(cursor)
+	if err != nil {
+		log.Printf("Failed to serialize download cursor: %v", err)
+		return
+	}
+	path := *s.config.Cursor
+	temporaryPath := path + ".tmp"
+	commons.WriteFile(temporaryPath, string(bytes))
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		log.Printf("Failed to replace download cursor %s: %v", path, err)
+	}
+}
+
+func (s *downloadScheduler) clearCursor() {
+	path := *s.config.Cursor
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("Failed to remove download cursor %s: %v", path, err)
+	}
+}
\ No newline at end of file
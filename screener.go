@@ -21,7 +21,7 @@ type screenerData struct {
 
 func runScreener() {
 	loadConfiguration()
-	config := configuration.Jump
+	config := getConfiguration().Jump
 	markets := getScreenerMarkets(false, config.IncludeTags, config.ExcludeTags, config.Threshold2.InexactFloat64(), config.Threshold3.InexactFloat64())
 	if markets == nil {
 		return
@@ -57,11 +57,12 @@ func runScreener() {
 		text := fmt.Sprintf(format, i + 1, market.Slug, firstPrice.Price, market.LastTradePrice, market.Spread, market.Volume1Wk, tagString)
 		if firstPrice.Price <= config.Threshold1.InexactFloat64() {
 			color.Green("%s\n", text)
-			beep()
+			sendAlert(alertInfo, market.Slug, "Jump candidate: first price = %.2f, last trade price = %.2f, spread = %.2f", firstPrice.Price, market.LastTradePrice, market.Spread)
 		} else {
 			fmt.Printf("%s\n", text)
 		}
 	}
+	waitForAlerts()
 }
 
 func getScreenerMarkets(negRisk bool, includeTags []string, excludeTags []string, priceMin, priceMax float64) []screenerData {
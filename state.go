@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/gammazero/deque"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	stateSaveInterval = 10
+)
+
+type stateStore struct {
+	path string
+	lastSave time.Time
+}
+
+type TriggerSystemState struct {
+	Triggers []TriggerState `json:"triggers"`
+	Prices map[string][]PriceEventState `json:"prices"`
+	Orders []TrackedOrderState `json:"orders"`
+	Risk *RiskState `json:"risk"`
+}
+
+type TriggerState struct {
+	Slug string `json:"slug"`
+	Triggered bool `json:"triggered"`
+	Pending bool `json:"pending"`
+	OrderID string `json:"orderId"`
+	HighWater decimal.Decimal `json:"highWater"`
+	BracketOrderID string `json:"bracketOrderId"`
+}
+
+type PriceEventState struct {
+	Timestamp time.Time `json:"timestamp"`
+	Price decimal.Decimal `json:"price"`
+	Size decimal.Decimal `json:"size"`
+}
+
+type JumpSystemState struct {
+	Subscriptions map[string]JumpSubscriptionState `json:"subscriptions"`
+	Orders []TrackedOrderState `json:"orders"`
+	Risk *RiskState `json:"risk"`
+}
+
+type JumpSubscriptionState struct {
+	Slug string `json:"slug"`
+	Triggered bool `json:"triggered"`
+	Prices []PriceEventState `json:"prices"`
+	Position *JumpPositionState `json:"position"`
+}
+
+type JumpPositionState struct {
+	Timestamp time.Time `json:"timestamp"`
+	Size decimal.Decimal `json:"size"`
+	Limit decimal.Decimal `json:"limit"`
+	Open bool `json:"open"`
+	Exiting bool `json:"exiting"`
+	OrderID string `json:"orderId"`
+}
+
+type TrackedOrderState struct {
+	ID string `json:"id"`
+	Slug string `json:"slug"`
+	TokenID string `json:"tokenId"`
+	Side string `json:"side"`
+	Size decimal.Decimal `json:"size"`
+	Limit decimal.Decimal `json:"limit"`
+	NegRisk bool `json:"negRisk"`
+	Posted time.Time `json:"posted"`
+	SizeMatched decimal.Decimal `json:"sizeMatched"`
+	Cost decimal.Decimal `json:"cost"`
+}
+
+type RiskState struct {
+	Day time.Time `json:"day"`
+	DailyProfit decimal.Decimal `json:"dailyProfit"`
+}
+
+func newStateStore(path *string) *stateStore {
+	if path == nil {
+		log.Printf("Warning: no state file has been configured, state will be lost on restart")
+		return nil
+	}
+	return &stateStore{
+		path: *path,
+		lastSave: time.Time{},
+	}
+}
+
+func (s *stateStore) load(state any) bool {
+	if s == nil || !commons.FileExists(s.path) {
+		return false
+	}
+	bytes, err := os.ReadFile(s.path)
+	if err != nil {
+		log.Fatalf("Failed to read state file %s: %v", s.path, err)
+	}
+	err = json.Unmarshal(bytes, state)
+	if err != nil {
+		log.Fatalf("Failed to deserialize state file %s: %v", s.path, err)
+	}
+	log.Printf("Restored state from %s", s.path)
+	return true
+}
+
+func (s *stateStore) save(getState func () any, force bool) {
+	if s == nil {
+		return
+	}
+	now := time.Now()
+	if !force && now.Sub(s.lastSave) < time.Duration(stateSaveInterval) * time.Second {
+		return
+	}
+	bytes, err := json.MarshalIndent(getState(), "", "\t")
+	if err != nil {
+		log.Printf("Failed to serialize state: %v", err)
+		return
+	}
+	temporaryPath := s.path + ".tmp"
+	commons.WriteFile(temporaryPath, string(bytes))
+	err = os.Rename(temporaryPath, s.path)
+	if err != nil {
+		log.Printf("Failed to replace state file %s: %v", s.path, err)
+		return
+	}
+	s.lastSave = now
+}
+
+func (s *tradingSystem) getState() any {
+	state := TriggerSystemState{
+		Triggers: []TriggerState{},
+		Prices: map[string][]PriceEventState{},
+		Orders: s.orders.getOrderStates(),
+		Risk: s.orders.getRiskState(),
+	}
+	for _, trigger := range s.triggers {
+		triggerState := TriggerState{
+			Slug: trigger.slug,
+			Triggered: trigger.triggered,
+			Pending: trigger.pending,
+			OrderID: trigger.orderID,
+			HighWater: trigger.highWater,
+			BracketOrderID: trigger.bracketOrderID,
+		}
+		state.Triggers = append(state.Triggers, triggerState)
+	}
+	for _, subscription := range s.subscriptions {
+		prices := []PriceEventState{}
+		for event := range subscription.prices.Iter() {
+			price := PriceEventState{
+				Timestamp: event.timestamp,
+				Price: event.price,
+				Size: event.size,
+			}
+			prices = append(prices, price)
+		}
+		state.Prices[subscription.assetID] = prices
+	}
+	return state
+}
+
+func (s *tradingSystem) restoreTrigger(trigger *triggerData) {
+	if s.restored == nil {
+		return
+	}
+	state, exists := commons.Find(s.restored.Triggers, func (t TriggerState) bool {
+		return t.Slug == trigger.slug
+	})
+	if !exists {
+		return
+	}
+	trigger.triggered = state.Triggered
+	trigger.pending = state.Pending
+	trigger.orderID = state.OrderID
+	trigger.highWater = state.HighWater
+	trigger.bracketOrderID = state.BracketOrderID
+	if trigger.pending && trigger.orderID == "" {
+		log.Printf("Warning: no order had been recorded for pending trigger \"%s\", resetting it", trigger.slug)
+		trigger.pending = false
+	}
+	if trigger.pending {
+		order := s.getTrackedOrder(trigger.orderID, trigger, decimal.Zero)
+		s.orders.track(order, s.getTriggerCallback(trigger))
+	}
+	if trigger.bracketOrderID != "" {
+		order := s.getTrackedOrder(trigger.bracketOrderID, trigger, trigger.trigger.TakeProfitLimit.Decimal)
+		s.orders.track(order, s.getBracketCallback(trigger))
+	}
+	log.Printf("Restored trigger \"%s\": triggered = %t, pending = %t", trigger.slug, trigger.triggered, trigger.pending)
+}
+
+func (s *tradingSystem) getTrackedOrder(orderID string, trigger *triggerData, limit decimal.Decimal) TrackedOrderState {
+	fallback := TrackedOrderState{
+		ID: orderID,
+		Slug: trigger.slug,
+		TokenID: trigger.assetID,
+		Side: sideSell,
+		Size: trigger.size,
+		Limit: limit,
+		NegRisk: false,
+		Posted: time.Time{},
+		SizeMatched: decimal.Zero,
+		Cost: decimal.Zero,
+	}
+	return findTrackedOrder(s.restored.Orders, fallback)
+}
+
+func (s *tradingSystem) restorePrices(subscription *marketSubscription) {
+	if s.restored == nil {
+		return
+	}
+	prices, exists := s.restored.Prices[subscription.assetID]
+	if !exists {
+		return
+	}
+	for _, price := range prices {
+		event := priceEvent{
+			timestamp: price.Timestamp,
+			price: price.Price,
+			size: price.Size,
+		}
+		subscription.prices.PushBack(event)
+	}
+	delete(s.restored.Prices, subscription.assetID)
+}
+
+func (s *jumpTradingSystem) getState() any {
+	state := JumpSystemState{
+		Subscriptions: map[string]JumpSubscriptionState{},
+		Orders: s.orders.getOrderStates(),
+		Risk: s.orders.getRiskState(),
+	}
+	for key, subscription := range s.subscriptions {
+		prices := []PriceEventState{}
+		for event := range subscription.prices.Iter() {
+			price := PriceEventState{
+				Timestamp: event.timestamp,
+				Price: event.price,
+				Size: decimal.Zero,
+			}
+			prices = append(prices, price)
+		}
+		subscriptionState := JumpSubscriptionState{
+			Slug: subscription.market.Slug,
+			Triggered: subscription.triggered,
+			Prices: prices,
+			Position: nil,
+		}
+		position := subscription.position
+		if position != nil && !position.closed {
+			subscriptionState.Position = &JumpPositionState{
+				Timestamp: position.timestamp,
+				Size: position.size,
+				Limit: position.limit,
+				Open: position.open,
+				Exiting: position.exiting,
+				OrderID: position.orderID,
+			}
+		}
+		state.Subscriptions[key] = subscriptionState
+	}
+	return state
+}
+
+func (s *jumpTradingSystem) restoreSubscription(key string, subscription *jumpSubscription) {
+	if s.restored == nil {
+		return
+	}
+	state, exists := s.restored.Subscriptions[key]
+	if !exists {
+		return
+	}
+	subscription.triggered = state.Triggered
+	subscription.prices = deque.Deque[jumpPriceEvent]{}
+	for _, price := range state.Prices {
+		event := jumpPriceEvent{
+			timestamp: price.Timestamp,
+			price: price.Price,
+		}
+		subscription.prices.PushBack(event)
+	}
+	if state.Position != nil {
+		position := &jumpPosition{
+			timestamp: state.Position.Timestamp,
+			size: state.Position.Size,
+			limit: state.Position.Limit,
+			open: state.Position.Open,
+			exiting: state.Position.Exiting,
+			closed: false,
+			canceling: false,
+			orderID: state.Position.OrderID,
+		}
+		if position.orderID == "" && !position.open {
+			log.Printf("Warning: no entry order had been recorded for %s, discarding the position", state.Slug)
+			position.closed = true
+		} else if position.orderID == "" && position.exiting {
+			log.Printf("Warning: no exit order had been recorded for %s, resetting the exit", state.Slug)
+			position.exiting = false
+		}
+		if !position.closed && position.orderID != "" && (!position.open || position.exiting) {
+			var onUpdate func (order managedOrder)
+			side := sideBuy
+			if position.exiting {
+				onUpdate = s.getExitCallback(position)
+				side = sideSell
+			} else {
+				onUpdate = s.getEntryCallback(position)
+			}
+			fallback := TrackedOrderState{
+				ID: position.orderID,
+				Slug: state.Slug,
+				TokenID: subscription.noID,
+				Side: side,
+				Size: position.size,
+				Limit: position.limit,
+				NegRisk: subscription.market.NegRisk,
+				Posted: time.Time{},
+				SizeMatched: decimal.Zero,
+				Cost: decimal.Zero,
+			}
+			s.orders.track(findTrackedOrder(s.restored.Orders, fallback), onUpdate)
+		}
+		subscription.position = position
+		log.Printf("Restored position in %s: size = %s, open = %t, exiting = %t", state.Slug, position.size, position.open, position.exiting)
+	}
+	delete(s.restored.Subscriptions, key)
+}
+
+func findTrackedOrder(orders []TrackedOrderState, fallback TrackedOrderState) TrackedOrderState {
+	order, exists := commons.Find(orders, func (o TrackedOrderState) bool {
+		return o.ID == fallback.ID
+	})
+	if exists {
+		return order
+	}
+	return fallback
+}
\ No newline at end of file
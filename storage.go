@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/encratite/gamma"
+)
+
+const (
+	storageMongo = "mongo"
+	storageFile = "file"
+)
+
+type storage interface {
+	close()
+	insertMarkets(markets []gamma.Market, assetIDs []string, eventSlugMap map[string]string)
+	insertBookMessage(message gamma.BookMessage, subscription marketSubscription)
+	insertGap(gap *CollectionGap)
+	closeGap(gap *CollectionGap, reconnected time.Time)
+	flushBuffer()
+	priceHistoryCheck(slug string, fidelity int) priceHistoryStatus
+	insertPriceHistory(history PriceHistoryBSON)
+	updatePriceHistory(history PriceHistoryBSON)
+	getPriceHistoryData(fidelity int, closed *bool, negRisk *bool, minVolume *float64, tag *string) []PriceHistoryBSON
+	getTagsOnly(fidelity int) []PriceHistoryBSON
+	upsertEvent(event EventBSON)
+	getEvents() []EventBSON
+	getMarkets() []MarketBSON
+	getBookEvents(assetID string) []BookEvent
+	getPriceChanges(assetID string) []PriceChangeBSON
+	getBookEventsInRange(start, end time.Time) []BookEvent
+	getLastTradePricesInRange(start, end time.Time) []LastTradePrice
+	getPriceChangesInRange(start, end time.Time) []PriceChangeBSON
+	getGapsInRange(start, end time.Time) []CollectionGap
+}
+
+func getHistoryCollection(fidelity int) string {
+	if fidelity == historyFidelity {
+		return historyCollection
+	}
+	return fmt.Sprintf("%s_%d", historyCollection, fidelity)
+}
+
+func getHistoryFidelity() int {
+	if getConfiguration() == nil {
+		return historyFidelity
+	}
+	return getConfiguration().History.Fidelities[0]
+}
+
+func newStorage() storage {
+	switch *getConfiguration().Database.Backend {
+	case storageMongo:
+		return newDatabaseClient()
+	case storageFile:
+		return newFileStorage(*getConfiguration().Database.Path)
+	default:
+		log.Fatalf("Unknown storage backend: %s", *getConfiguration().Database.Backend)
+		return nil
+	}
+}
\ No newline at end of file
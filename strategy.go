@@ -1,11 +1,24 @@
 package main
 
 import (
+	"log"
 	"time"
 
 	"github.com/encratite/commons"
+	"github.com/gammazero/deque"
 )
 
+type strategyFactory func(tags []string, excludeTags []string, parameters strategyParameters) backtestStrategy
+
+type strategyParameters map[string]any
+
+var strategyRegistry = map[string]strategyFactory{
+	"decay": newDecayStrategy,
+	"threshold": newThresholdStrategy,
+	"jump": newJumpStrategy,
+	"mention": newMentionStrategy,
+}
+
 type decayStrategy struct {
 	tags []string
 	triggerPriceMin float64
@@ -15,12 +28,6 @@ type decayStrategy struct {
 	priceRangeCheck bool
 }
 
-type StrategyResult struct {
-	Tag string `json:"tag"`
-	Parameter string `json:"parameter"`
-	SharpeRatio float64 `json:"sharpeRatio"`
-}
-
 type thresholdStrategy struct {
 	tags []string
 	threshold float64
@@ -39,9 +46,12 @@ type jumpStrategy struct {
 	positionSize float64
 	holdingTime int
 	previousPrices map[string]priceSample
+	priceWindows map[string]*deque.Deque[priceSample]
+	includedMarkets map[string]bool
 }
 
 type mentionStrategy struct {
+	tags []string
 	threshold1 float64
 	threshold2 float64
 	minSamples int
@@ -54,6 +64,59 @@ type priceSample struct {
 	price float64
 }
 
+func newDecayStrategy(tags []string, excludeTags []string, parameters strategyParameters) backtestStrategy {
+	return &decayStrategy{
+		tags: tags,
+		triggerPriceMin: parameters.getFloat("triggerPriceMin"),
+		triggerPriceMax: parameters.getFloat("triggerPriceMax"),
+		positionSize: parameters.getFloat("positionSize"),
+		holdingTime: parameters.getInt("holdingTime"),
+		priceRangeCheck: parameters.getBool("priceRangeCheck"),
+	}
+}
+
+func newThresholdStrategy(tags []string, excludeTags []string, parameters strategyParameters) backtestStrategy {
+	return &thresholdStrategy{
+		tags: tags,
+		threshold: parameters.getFloat("threshold"),
+		greaterThan: parameters.getBool("greaterThan"),
+		positionSize: parameters.getFloat("positionSize"),
+		side: parameters.getSide("side"),
+	}
+}
+
+func newJumpStrategy(tags []string, excludeTags []string, parameters strategyParameters) backtestStrategy {
+	return &jumpStrategy{
+		includeTags: tags,
+		excludeTags: excludeTags,
+		threshold1: parameters.getFloat("threshold1"),
+		threshold2: parameters.getFloat("threshold2"),
+		threshold3: parameters.getFloat("threshold3"),
+		stopLoss: parameters.getBool("stopLoss"),
+		positionSize: parameters.getFloat("positionSize"),
+		holdingTime: parameters.getInt("holdingTime"),
+		previousPrices: map[string]priceSample{},
+		priceWindows: map[string]*deque.Deque[priceSample]{},
+		includedMarkets: map[string]bool{},
+	}
+}
+
+func newMentionStrategy(tags []string, excludeTags []string, parameters strategyParameters) backtestStrategy {
+	if len(tags) == 0 {
+		tags = []string{
+			"mention-markets",
+		}
+	}
+	return &mentionStrategy{
+		tags: tags,
+		threshold1: parameters.getFloat("threshold1"),
+		threshold2: parameters.getFloat("threshold2"),
+		minSamples: parameters.getInt("minSamples"),
+		positionSize: parameters.getFloat("positionSize"),
+		sampleCounts: map[string]int{},
+	}
+}
+
 func (s *decayStrategy) next(backtest *backtestData) {
 	markets := backtest.getMarkets(s.tags)
 	for _, market := range markets {
@@ -139,6 +202,10 @@ func (s *jumpStrategy) next(backtest *backtestData) {
 			price: price,
 		}
 	}
+	s.closeJumpPositions(backtest)
+}
+
+func (s *jumpStrategy) closeJumpPositions(backtest *backtestData) {
 	for _, position := range backtest.positions {
 		expired := backtest.now.Sub(position.timestamp) >= time.Duration(s.holdingTime) * time.Hour
 		stopLoss := false
@@ -155,10 +222,7 @@ func (s *jumpStrategy) next(backtest *backtestData) {
 }
 
 func (s *mentionStrategy) next(backtest *backtestData) {
-	tags := []string{
-		"mention-markets",
-	}
-	markets := backtest.getMarkets(tags)
+	markets := backtest.getMarkets(s.tags)
 	for _, market := range markets {
 		slug := market.Slug
 		exists := commons.ContainsFunc(backtest.positions, func (p backtestPosition) bool {
@@ -178,4 +242,54 @@ func (s *mentionStrategy) next(backtest *backtestData) {
 			}
 		}
 	}	
+}
+
+func (p strategyParameters) get(name string) any {
+	value, exists := p[name]
+	if !exists {
+		log.Fatalf("Missing strategy parameter \"%s\"", name)
+	}
+	return value
+}
+
+func (p strategyParameters) getFloat(name string) float64 {
+	switch value := p.get(name).(type) {
+	case float64:
+		return value
+	case int:
+		return float64(value)
+	}
+	log.Fatalf("Strategy parameter \"%s\" must be a number", name)
+	return 0.0
+}
+
+func (p strategyParameters) getInt(name string) int {
+	value := p.getFloat(name)
+	if value != float64(int(value)) {
+		log.Fatalf("Strategy parameter \"%s\" must be an integer", name)
+	}
+	return int(value)
+}
+
+func (p strategyParameters) getBool(name string) bool {
+	value, ok := p.get(name).(bool)
+	if !ok {
+		log.Fatalf("Strategy parameter \"%s\" must be a boolean", name)
+	}
+	return value
+}
+
+func (p strategyParameters) getSide(name string) backtestPositionSide {
+	value, ok := p.get(name).(string)
+	if !ok {
+		log.Fatalf("Strategy parameter \"%s\" must be a string", name)
+	}
+	switch value {
+	case "yes":
+		return sideYes
+	case "no":
+		return sideNo
+	}
+	log.Fatalf("Invalid side in strategy parameter \"%s\": %s", name, value)
+	return sideNo
 }
\ No newline at end of file
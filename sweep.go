@@ -0,0 +1,464 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+const (
+	sweepDefaultMetric = "sharpe"
+	sweepTagsParameter = "tags"
+	sweepPrecision = 1e10
+)
+
+type SweepDefinition struct {
+	Metric *string `yaml:"metric"`
+	Dimensions []SweepDimension `yaml:"dimensions"`
+	Table *string `yaml:"table"`
+	Heatmap *string `yaml:"heatmap"`
+	Plot bool `yaml:"plot"`
+}
+
+type SweepDimension struct {
+	Parameter *string `yaml:"parameter"`
+	Values []any `yaml:"values"`
+	Labels []string `yaml:"labels"`
+	Linear *SweepRange `yaml:"linear"`
+	Log *SweepRange `yaml:"log"`
+}
+
+type SweepRange struct {
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+	Steps *int `yaml:"steps"`
+}
+
+type SweepHeatmap struct {
+	Title string `json:"title"`
+	XLabel string `json:"xLabel"`
+	YLabel string `json:"yLabel"`
+	Cells []SweepHeatmapCell `json:"cells"`
+}
+
+type SweepHeatmapCell struct {
+	X string `json:"x"`
+	Y string `json:"y"`
+	Value float64 `json:"value"`
+}
+
+type backtestMetric struct {
+	name string
+	higherIsBetter bool
+	get func (result *backtestResult) float64
+	format func (value float64) string
+}
+
+type sweepValue struct {
+	label string
+	value any
+}
+
+type sweepCombination struct {
+	labels []string
+	tags []string
+	parameters strategyParameters
+}
+
+type sweepResult struct {
+	combination sweepCombination
+	result backtestResult
+	value float64
+}
+
+var backtestMetrics = map[string]backtestMetric{
+	"sharpe": {
+		name: "Sharpe ratio",
+		higherIsBetter: true,
+		get: func (result *backtestResult) float64 {
+			return result.sharpeRatio
+		},
+		format: func (value float64) string {
+			return fmt.Sprintf("%.2f", value)
+		},
+	},
+	"return": {
+		name: "Total return",
+		higherIsBetter: true,
+		get: func (result *backtestResult) float64 {
+			return result.totalReturn
+		},
+		format: func (value float64) string {
+			return fmt.Sprintf("%+.1f%%", percent * value)
+		},
+	},
+	"drawdown": {
+		name: "Max drawdown",
+		higherIsBetter: false,
+		get: func (result *backtestResult) float64 {
+			return result.maxDrawdown
+		},
+		format: func (value float64) string {
+			return fmt.Sprintf("%.2f%%", percent * value)
+		},
+	},
+	"trades": {
+		name: "Trades",
+		higherIsBetter: true,
+		get: func (result *backtestResult) float64 {
+			return float64(result.trades)
+		},
+		format: func (value float64) string {
+			return fmt.Sprintf("%.0f", value)
+		},
+	},
+	"cash": {
+		name: "Cash",
+		higherIsBetter: true,
+		get: func (result *backtestResult) float64 {
+			return result.cash
+		},
+		format: commons.FormatMoney,
+	},
+}
+
+func runSweep(definition *BacktestDefinition) {
+	sweep := definition.Sweep
+	metric := sweep.getMetric()
+	combinations := sweep.getCombinations(definition)
+	start := definition.Start.Time
+	end := definition.End.Time
+	log.Printf("Running %d backtests", len(combinations))
+	data := definition.loadData()
+	fill := newFillModel(*definition.FillModel)
+	defer fill.close()
+	sweepStart := time.Now()
+	results := executeSweep(definition, combinations, metric, start, end, fill, data)
+	sweepDuration := time.Since(sweepStart)
+	fmt.Printf("Sweep finished after %.1f s\n", sweepDuration.Seconds())
+	sweep.printTable(metric, results)
+	if sweep.Table != nil {
+		sweep.writeTable(*sweep.Table, results)
+	}
+	heatmap := sweep.getHeatmap(metric, results)
+	if sweep.Heatmap != nil {
+		writeJSON(*sweep.Heatmap, heatmap)
+	}
+	if sweep.Plot {
+		plotData("heatmap", heatmap)
+	}
+}
+
+func executeSweep(
+	definition *BacktestDefinition,
+	combinations []sweepCombination,
+	metric backtestMetric,
+	start time.Time,
+	end time.Time,
+	fill fillModel,
+	data *backtestDataSet,
+) []sweepResult {
+	factory := strategyRegistry[*definition.Strategy]
+	results := commons.ParallelMap(combinations, func (combination sweepCombination) sweepResult {
+		strategy := factory(combination.tags, definition.ExcludeTags, combination.parameters)
+		result := definition.execute(strategy, start, end, fill, data)
+		return sweepResult{
+			combination: combination,
+			result: result,
+			value: metric.get(&result),
+		}
+	})
+	return results
+}
+
+func getBestSweepResult(metric backtestMetric, results []sweepResult) sweepResult {
+	return slices.MaxFunc(results, func (a, b sweepResult) int {
+		if metric.higherIsBetter {
+			return cmp.Compare(a.value, b.value)
+		} else {
+			return cmp.Compare(b.value, a.value)
+		}
+	})
+}
+
+func (s *SweepDefinition) validate() {
+	if s.Metric == nil {
+		metric := sweepDefaultMetric
+		s.Metric = &metric
+	}
+	_, exists := backtestMetrics[*s.Metric]
+	if !exists {
+		log.Fatalf("Unknown metric in sweep definition: %s", *s.Metric)
+	}
+	if len(s.Dimensions) == 0 {
+		log.Fatalf("Sweep definition requires at least one dimension")
+	}
+	for i := range s.Dimensions {
+		s.Dimensions[i].validate()
+	}
+}
+
+func (s *SweepDefinition) getMetric() backtestMetric {
+	return backtestMetrics[*s.Metric]
+}
+
+func (s *SweepDefinition) getCombinations(definition *BacktestDefinition) []sweepCombination {
+	combinations := []sweepCombination{
+		{
+			labels: []string{},
+			tags: definition.Tags,
+			parameters: definition.Parameters,
+		},
+	}
+	for _, dimension := range s.Dimensions {
+		values := dimension.getValues()
+		newCombinations := []sweepCombination{}
+		for _, combination := range combinations {
+			for _, value := range values {
+				newCombination := combination.copy()
+				newCombination.labels = append(newCombination.labels, value.label)
+				if dimension.Parameter != nil {
+					newCombination.set(*dimension.Parameter, value.value)
+				} else {
+					assignments := value.value.(map[string]any)
+					for parameter, assignment := range assignments {
+						newCombination.set(parameter, assignment)
+					}
+				}
+				newCombinations = append(newCombinations, newCombination)
+			}
+		}
+		combinations = newCombinations
+	}
+	return combinations
+}
+
+func (s *SweepDefinition) printTable(metric backtestMetric, results []sweepResult) {
+	header := []string{}
+	alignments := []tw.Align{}
+	for _, dimension := range s.Dimensions {
+		header = append(header, dimension.getName())
+		alignments = append(alignments, tw.AlignDefault)
+	}
+	header = append(header, metric.name)
+	alignments = append(alignments, tw.AlignRight)
+	rows := [][]string{}
+	for _, result := range results {
+		row := slices.Clone(result.combination.labels)
+		row = append(row, metric.format(result.value))
+		rows = append(rows, row)
+	}
+	tableConfig := tablewriter.WithConfig(tablewriter.Config{
+		Header: tw.CellConfig{
+			Formatting: tw.CellFormatting{AutoFormat: tw.Off},
+			Alignment: tw.CellAlignment{Global: tw.AlignLeft},
+		}},
+	)
+	alignmentConfig := tablewriter.WithAlignment(alignments)
+	table := tablewriter.NewTable(os.Stdout, tableConfig, alignmentConfig)
+	table.Header(header)
+	table.Bulk(rows)
+	table.Render()
+	best := getBestSweepResult(metric, results)
+	bestLabel := strings.Join(best.combination.labels, ", ")
+	fmt.Printf("\nBest %s: %s (%s)\n", strings.ToLower(metric.name), metric.format(best.value), bestLabel)
+}
+
+func (s *SweepDefinition) writeTable(path string, results []sweepResult) {
+	columns := []string{}
+	for _, dimension := range s.Dimensions {
+		columns = append(columns, dimension.getName())
+	}
+	columns = append(columns, *s.Metric)
+	output := strings.Join(columns, ",") + "\n"
+	for _, result := range results {
+		row := slices.Clone(result.combination.labels)
+		row = append(row, fmt.Sprintf("%g", result.value))
+		output += strings.Join(row, ",") + "\n"
+	}
+	commons.WriteFile(path, output)
+	log.Printf("Wrote %s (%d records)", path, len(results))
+}
+
+func (s *SweepDefinition) getHeatmap(metric backtestMetric, results []sweepResult) SweepHeatmap {
+	last := len(s.Dimensions) - 1
+	yNames := []string{}
+	for _, dimension := range s.Dimensions[:last] {
+		yNames = append(yNames, dimension.getName())
+	}
+	cells := []SweepHeatmapCell{}
+	for _, result := range results {
+		labels := result.combination.labels
+		cell := SweepHeatmapCell{
+			X: labels[last],
+			Y: strings.Join(labels[:last], ", "),
+			Value: result.value,
+		}
+		cells = append(cells, cell)
+	}
+	heatmap := SweepHeatmap{
+		Title: metric.name,
+		XLabel: s.Dimensions[last].getName(),
+		YLabel: strings.Join(yNames, ", "),
+		Cells: cells,
+	}
+	return heatmap
+}
+
+func (d *SweepDimension) validate() {
+	sources := 0
+	if len(d.Values) > 0 {
+		sources++
+	}
+	if d.Linear != nil {
+		d.Linear.validate(false)
+		sources++
+	}
+	if d.Log != nil {
+		d.Log.validate(true)
+		sources++
+	}
+	if sources != 1 {
+		log.Fatalf("Sweep dimensions require exactly one of values, linear or log")
+	}
+	if d.Labels != nil && len(d.Labels) != len(d.Values) {
+		log.Fatalf("The number of labels must match the number of values in a sweep dimension")
+	}
+	if d.Parameter == nil {
+		if d.Linear != nil || d.Log != nil {
+			log.Fatalf("Linear and logarithmic sweep dimensions require a parameter name")
+		}
+		for _, value := range d.Values {
+			_, ok := value.(map[string]any)
+			if !ok {
+				log.Fatalf("Sweep dimensions without a parameter name require mappings as values")
+			}
+		}
+	}
+}
+
+func (d *SweepDimension) getName() string {
+	if d.Parameter != nil {
+		return *d.Parameter
+	}
+	keys := []string{}
+	for key := range d.Values[0].(map[string]any) {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return strings.Join(keys, "/")
+}
+
+func (d *SweepDimension) getValues() []sweepValue {
+	values := []sweepValue{}
+	if d.Linear != nil || d.Log != nil {
+		var steps []float64
+		if d.Linear != nil {
+			steps = d.Linear.getLinearSteps()
+		} else {
+			steps = d.Log.getLogSteps()
+		}
+		for _, step := range steps {
+			value := sweepValue{
+				label: fmt.Sprintf("%g", step),
+				value: step,
+			}
+			values = append(values, value)
+		}
+		return values
+	}
+	for i, value := range d.Values {
+		var label string
+		if d.Labels != nil {
+			label = d.Labels[i]
+		} else {
+			label = fmt.Sprintf("%v", value)
+		}
+		sweepValue := sweepValue{
+			label: label,
+			value: value,
+		}
+		values = append(values, sweepValue)
+	}
+	return values
+}
+
+func (r *SweepRange) validate(logarithmic bool) {
+	if r.Min == nil || r.Max == nil || r.Steps == nil {
+		log.Fatalf("Sweep ranges require min, max and steps")
+	}
+	if *r.Min >= *r.Max {
+		log.Fatalf("Sweep range minimum must be less than maximum")
+	}
+	if *r.Steps < 2 {
+		log.Fatalf("Sweep ranges require at least two steps")
+	}
+	if logarithmic && *r.Min <= 0.0 {
+		log.Fatalf("Logarithmic sweep ranges require a positive minimum")
+	}
+}
+
+func (r *SweepRange) getLinearSteps() []float64 {
+	steps := []float64{}
+	delta := (*r.Max - *r.Min) / float64(*r.Steps - 1)
+	for i := range *r.Steps {
+		step := *r.Min + float64(i) * delta
+		steps = append(steps, roundSweepValue(step))
+	}
+	return steps
+}
+
+func (r *SweepRange) getLogSteps() []float64 {
+	steps := []float64{}
+	factor := math.Pow(*r.Max / *r.Min, 1.0 / float64(*r.Steps - 1))
+	for i := range *r.Steps {
+		step := *r.Min * math.Pow(factor, float64(i))
+		steps = append(steps, roundSweepValue(step))
+	}
+	return steps
+}
+
+func (c *sweepCombination) copy() sweepCombination {
+	parameters := strategyParameters{}
+	for key, value := range c.parameters {
+		parameters[key] = value
+	}
+	return sweepCombination{
+		labels: slices.Clone(c.labels),
+		tags: c.tags,
+		parameters: parameters,
+	}
+}
+
+func (c *sweepCombination) set(parameter string, value any) {
+	if parameter != sweepTagsParameter {
+		c.parameters[parameter] = value
+		return
+	}
+	switch tags := value.(type) {
+	case string:
+		c.tags = []string{
+			tags,
+		}
+	case []any:
+		c.tags = []string{}
+		for _, tag := range tags {
+			c.tags = append(c.tags, fmt.Sprintf("%v", tag))
+		}
+	default:
+		log.Fatalf("Invalid tags in sweep definition: %v", value)
+	}
+}
+
+func roundSweepValue(value float64) float64 {
+	return math.Round(value * sweepPrecision) / sweepPrecision
+}
\ No newline at end of file
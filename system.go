@@ -18,7 +18,6 @@ import (
 )
 
 const (
-	reconnectDelay = 5
 	debugPriceChange = false
 	debugLastTradePrice = true
 	debugOrderBook = false
@@ -37,8 +36,21 @@ type tradingSystem struct {
 	mode tradingSystemMode
 	markets []gamma.Market
 	subscriptions map[string]marketSubscription
-	database databaseClient
-	triggers []triggerData
+	database storage
+	triggers []*triggerData
+	orders *orderManager
+	paper *paperBroker
+	state *stateStore
+	restored *TriggerSystemState
+	watcher *configurationWatcher
+	loop *eventLoop
+	resubscribe bool
+	assets map[string]bool
+	generation int
+	dropped map[string]bool
+	shards int
+	gap *CollectionGap
+	replay bool
 }
 
 type marketSubscription struct {
@@ -49,6 +61,7 @@ type marketSubscription struct {
 	prices deque.Deque[priceEvent]
 	bids *treemap.Map
 	asks *treemap.Map
+	integrity bookIntegrity
 }
 
 type priceEvent struct {
@@ -63,17 +76,67 @@ type triggerData struct {
 	size decimal.Decimal
 	trigger Trigger
 	triggered bool
+	pending bool
+	orderID string
+	highWater decimal.Decimal
+	exitTime *time.Time
+	bracketOrderID string
+	exitLimit *decimal.Decimal
+	exitNegRisk bool
+	replaceBracket bool
+	bracketPending bool
 }
 
 func runMode(mode tradingSystemMode) {
 	loadConfiguration()
-	database := newDatabaseClient()
+	database := newStorage()
 	system := tradingSystem{
 		mode: mode,
 		markets: []gamma.Market{},
 		subscriptions: map[string]marketSubscription{},
 		database: database,
-		triggers: []triggerData{},
+		triggers: []*triggerData{},
+		orders: nil,
+		paper: nil,
+		state: nil,
+		restored: nil,
+		watcher: nil,
+		loop: newEventLoop(false),
+		resubscribe: false,
+		assets: map[string]bool{},
+		generation: 0,
+		dropped: map[string]bool{},
+		shards: 0,
+		gap: nil,
+		replay: false,
+	}
+	client, ok := database.(*databaseClient)
+	if mode == systemDataMode && getConfiguration().Data.WAL != nil && ok {
+		client.enableWAL(*getConfiguration().Data.WAL)
+	}
+	system.watcher = newConfigurationWatcher(nil, func (next *Configuration) {
+		system.loop.post(func () {
+			system.onReload(next)
+		})
+	})
+	var executor orderExecutor = newCLOBExecutor(*getConfiguration().Trigger.Live)
+	if mode == systemTriggerMode && *getConfiguration().Trigger.Paper {
+		system.paper = newPaperBroker()
+		executor = system.paper
+	}
+	system.orders = newOrderManager(executor)
+	system.orders.dispatch = system.loop.post
+	if mode == systemTriggerMode {
+		system.orders.enableRiskManagement(*getConfiguration().Trigger.Live)
+	}
+	system.orders.run()
+	if mode == systemTriggerMode {
+		system.state = newStateStore(getConfiguration().Trigger.State)
+		var restored TriggerSystemState
+		if system.state.load(&restored) {
+			system.restored = &restored
+			system.orders.restoreRisk(restored.Risk)
+		}
 	}
 	system.run()
 }
@@ -81,10 +144,16 @@ func runMode(mode tradingSystemMode) {
 func (s *tradingSystem) run() {
 	defer s.database.close()
 	s.interrupt()
-	if s.mode == systemTriggerMode && *configuration.Trigger.Live {
+	s.loop.startTimer(s.onTimer)
+	if s.mode == systemDataMode {
+		go s.discoverMarkets()
+	}
+	if s.mode == systemTriggerMode && *getConfiguration().Trigger.Live {
 		log.Printf("Warning: system is LIVE and has permission to post orders")
 	}
+	backoff := newReconnectBackoff()
 	for {
+		backoff.connect()
 		switch s.mode {
 		case systemDataMode:
 			s.runDataMode()
@@ -93,36 +162,29 @@ func (s *tradingSystem) run() {
 		default:
 			log.Fatalf("Unknown system mode: %d", s.mode)
 		}
-		time.Sleep(time.Duration(reconnectDelay) * time.Second)
+		backoff.wait()
 	}
 }
 
 func (s *tradingSystem) runDataMode() {
-	markets, eventSlugMap, err := getEventMarkets()
+	markets, eventSlugMap, err := getDataModeMarkets()
 	if err != nil {
 		return
 	}
 	printMarketStats(markets)
-	for _, eventSlug := range configuration.Data.Events {
-		event, err := gamma.GetEventBySlug(eventSlug)
-		if err != nil {
-			return
-		}
-		for _, market := range event.Markets {
-			eventSlugMap[market.Slug] = event.Slug
-			markets = append(markets, market)
-		}
-		log.Printf("Loaded %d additional markets from event %s", len(event.Markets), eventSlug)
-	}
-	s.markets = markets
 	assetIDs := getAssetIDs(markets)
-	s.database.insertMarkets(markets, assetIDs, eventSlugMap)
+	s.loop.invoke(func () {
+		s.markets = markets
+		s.dropped = map[string]bool{}
+		s.shards = 0
+		s.database.insertMarkets(markets, assetIDs, eventSlugMap)
+	})
 	log.Printf("Subscribed to %d markets", len(assetIDs))
 	s.subscribe(assetIDs)
 }
 
 func (s *tradingSystem) runTriggerMode() {
-	positions, err := gamma.GetPositions(configuration.Credentials.ProxyAddress)
+	positions, err := gamma.GetPositions(getConfiguration().Credentials.ProxyAddress)
 	if err != nil {
 		return
 	}
@@ -139,36 +201,121 @@ func (s *tradingSystem) runTriggerMode() {
 			markets = append(markets, market)
 		}
 	}
-	s.markets = markets
 	assetIDs := []string{}
-	for _, trigger := range configuration.Trigger.Triggers {
+	s.loop.invoke(func () {
+		s.markets = markets
+		assetIDs = s.updateTriggers(positions)
+	})
+	s.subscribe(assetIDs)
+}
+
+func (s *tradingSystem) updateTriggers(positions []gamma.Position) []string {
+	assetIDs := []string{}
+	restore := len(s.triggers) == 0
+	initial := s.generation == 0
+	for _, trigger := range getConfiguration().Trigger.Triggers {
 		slug := *trigger.Slug
 		position, exists := commons.Find(positions, func (p gamma.Position) bool {
 			return p.Slug == slug
 		})
 		assetID := position.Asset
 		if !exists {
-			log.Fatalf("Unable to find a position matching trigger slug \"%s\"", slug)
+			triggered := commons.ContainsFunc(s.triggers, func (t *triggerData) bool {
+				return t.slug == slug && t.triggered
+			})
+			if triggered {
+				continue
+			}
+			if initial {
+				log.Fatalf("Unable to find a position matching trigger slug \"%s\"", slug)
+			}
+			log.Printf("Skipping trigger \"%s\", unable to find a matching position", slug)
+			sendAlert(alertWarning, slug, "Skipping trigger, unable to find a matching position")
+			continue
 		}
 		assetIDs = append(assetIDs, assetID)
 		log.Printf("Subscribed to market \"%s\"", slug)
+		previous, exists := commons.Find(s.triggers, func (t *triggerData) bool {
+			return t.slug == slug
+		})
+		if exists {
+			if !previous.pending && previous.bracketOrderID == "" {
+				previous.size = decimal.NewFromFloat(position.Size)
+			}
+			continue
+		}
+		market, _ := s.getMarketBySlug(slug)
 		data := triggerData{
 			slug: slug,
 			assetID: assetID,
 			size: decimal.NewFromFloat(position.Size),
 			trigger: trigger,
 			triggered: false,
+			pending: false,
+			orderID: "",
+			highWater: decimal.Zero,
+			exitTime: getTriggerExitTime(trigger, market),
+			bracketOrderID: "",
+			exitLimit: nil,
+			exitNegRisk: false,
+			replaceBracket: false,
+			bracketPending: false,
 		}
-		s.triggers = append(s.triggers, data)
+		if data.exitTime != nil {
+			log.Printf("Trigger for \"%s\" will exit at %s", slug, commons.GetTimeString(*data.exitTime))
+		}
+		s.triggers = append(s.triggers, &data)
 	}
-	s.subscribe(assetIDs)
+	if restore {
+		for _, trigger := range s.triggers {
+			s.restoreTrigger(trigger)
+		}
+		s.saveState(true)
+	}
+	s.placeBracketOrders()
+	return assetIDs
 }
 
 func (s *tradingSystem) subscribe(assetIDs []string) {
-	err := gamma.SubscribeToMarkets(assetIDs, s.onBookMessage)
+	var generation int
+	var gap *CollectionGap
+	s.loop.invoke(func () {
+		s.generation++
+		generation = s.generation
+		gap = s.gap
+		for _, assetID := range assetIDs {
+			s.assets[assetID] = true
+		}
+	})
+	err := gamma.SubscribeToMarkets(assetIDs, s.getBookCallback(generation, gap))
 	if err != nil {
 		log.Printf("Subscription error: %v", err)
 	}
+	disconnected := time.Now()
+	s.loop.invoke(func () {
+		s.gap = s.recordGap(gap, assetIDs, disconnected, err)
+	})
+}
+
+func (s *tradingSystem) recordGap(previous *CollectionGap, assetIDs []string, disconnected time.Time, err error) *CollectionGap {
+	if s.mode != systemDataMode {
+		return nil
+	}
+	if previous != nil && previous.Reconnected == nil {
+		return previous
+	}
+	reason := "resubscribe"
+	if err != nil {
+		reason = err.Error()
+	}
+	gap := &CollectionGap{
+		AssetIDs: assetIDs,
+		Disconnected: disconnected,
+		Reconnected: nil,
+		Reason: reason,
+	}
+	s.database.insertGap(gap)
+	return gap
 }
 
 func (s *tradingSystem) interrupt() {
@@ -177,35 +324,106 @@ func (s *tradingSystem) interrupt() {
 	go func() {
 		<-interrupt
 		log.Println("Received interrupt signal, flushing buffer")
-		s.database.flushBuffer()
+		s.loop.invoke(func () {
+			s.database.flushBuffer()
+			s.saveState(true)
+		})
 		os.Exit(0)
 	}()
 }
 
 func (s *tradingSystem) onBookMessage(message gamma.BookMessage) bool {
+	result := true
+	s.loop.invoke(func () {
+		result = s.processBookMessage(message)
+	})
+	return result
+}
+
+func (s *tradingSystem) getBookCallback(generation int, gap *CollectionGap) func (message gamma.BookMessage) bool {
+	return func (message gamma.BookMessage) bool {
+		result := true
+		s.loop.invoke(func () {
+			if generation != s.generation {
+				result = false
+				return
+			}
+			if gap != nil && gap.Reconnected == nil {
+				s.database.closeGap(gap, time.Now())
+				log.Printf("Reconnected after a gap of %.1f s", gap.Reconnected.Sub(gap.Disconnected).Seconds())
+			}
+			result = s.processBookMessage(message)
+			if !result {
+				s.generation++
+			}
+		})
+		return result
+	}
+}
+
+func (s *tradingSystem) processBookMessage(message gamma.BookMessage) bool {
+	if s.resubscribe {
+		s.resubscribe = false
+		return false
+	}
+	if s.dropped[message.Market] {
+		return true
+	}
+	s.updateSubscription(message)
+	return true
+}
+
+func (s *tradingSystem) updateSubscription(message gamma.BookMessage) {
 	subscription, exists := s.getSubscription(message)
 	if !exists {
-		return true
+		return
 	}
-	switch message.EventType {
-	case gamma.BookEvent:
+	subscribed := message.EventType == gamma.PriceChangeEvent || message.AssetID == subscription.assetID
+	switch {
+	case !subscribed:
+	case message.EventType == gamma.BookEvent:
 		s.onBookEvent(message, &subscription)
-	case gamma.PriceChangeEvent:
+	case message.EventType == gamma.PriceChangeEvent:
 		s.onPriceChange(message, &subscription)
-	case gamma.LastTradePriceEvent:
+	case message.EventType == gamma.LastTradePriceEvent:
 		s.onLastTradePrice(message, &subscription)
 	}
-	if s.mode == systemDataMode || (s.mode == systemTriggerMode && *configuration.Trigger.RecordData) {
+	if !s.replay && (s.mode == systemDataMode || (s.mode == systemTriggerMode && *getConfiguration().Trigger.RecordData)) {
 		s.database.insertBookMessage(message, subscription)
 	}
-	_ = subscription.validateOrderBook()
+	if subscribed {
+		subscription.integrity.checkCrossed(subscription.slug, subscription.bids, subscription.asks)
+		if s.paper != nil {
+			s.paper.update(subscription.assetID, subscription.bids, subscription.asks, message)
+		}
+		if s.mode == systemTriggerMode {
+			s.checkTimeExit(&subscription)
+		}
+	}
+	if subscription.integrity.stale && !s.replay && shouldResync(&subscription.integrity, subscription.slug) {
+		requestBookSnapshot(subscription.conditionID, subscription.assetID, s.loop, s.updateSubscription)
+	}
 	s.subscriptions[message.Market] = subscription
-	return true
+	s.saveState(false)
+}
+
+func (s *tradingSystem) onTimer() {
+	if s.mode == systemTriggerMode {
+		for _, subscription := range s.subscriptions {
+			s.checkTimeExit(&subscription)
+		}
+	}
+	s.saveState(false)
+}
+
+func (s *tradingSystem) saveState(force bool) {
+	s.state.save(s.getState, force)
 }
 
 func (s *tradingSystem) onBookEvent(message gamma.BookMessage, subscription *marketSubscription) {
 	putPriceLevels(subscription.bids, message.Bids)
 	putPriceLevels(subscription.asks, message.Asks)
+	subscription.integrity.onSnapshot(subscription.slug)
 	if debugOrderBook {
 		subscription.printOrderBook()
 	}
@@ -217,58 +435,65 @@ func (s *tradingSystem) onPriceChange(message gamma.BookMessage, subscription *m
 			format := "%s[%d]: slug = %s, conditionID = %s, assetID = %s, price = %s, size = %s, side = %s, best_bid = %s, best_ask = %s"
 			log.Printf(format, gamma.PriceChangeEvent, i, subscription.slug, subscription.conditionID, subscription.assetID, change.Price, change.Size, change.Side, change.BestBid, change.BestAsk)
 		}
-		price, size, err := getPriceSize(change.Price, change.Size)
-		if err != nil {
-			continue
-		}
-		var side, otherSide *treemap.Map
-		var bid bool
-		switch change.Side {
-		case sideBuy:
-			bid = true
-			side = subscription.bids
-			otherSide = subscription.asks
-		case sideSell:
-			bid = false
-			side = subscription.asks
-			otherSide = subscription.bids
-		default:
-			continue
+		if change.AssetID == subscription.assetID {
+			updateOrderBook(subscription.bids, subscription.asks, change)
+			subscription.integrity.checkPriceChange(subscription.slug, subscription.bids, subscription.asks, change)
 		}
-		if size.IsPositive() {
-			side.Put(price, size)
-			removeKeys := []decimal.Decimal{}
-			it := otherSide.Iterator()
-			if bid {
-				for it.Next() {
-					key := it.Key().(decimal.Decimal)
-					if key.LessThanOrEqual(price) {
-						removeKeys = append(removeKeys, key)
-					}
-				}
-			} else {
-				it.End()
-				for it.Prev() {
-					key := it.Key().(decimal.Decimal)
-					if key.GreaterThanOrEqual(price) {
-						removeKeys = append(removeKeys, key)
-					}
+	}
+	if debugOrderBook {
+		subscription.printOrderBook()
+	}
+}
+
+func updateOrderBook(bids, asks *treemap.Map, change gamma.PriceChange) {
+	price, size, err := getPriceSize(change.Price, change.Size)
+	if err != nil {
+		return
+	}
+	var side, otherSide *treemap.Map
+	var bid bool
+	switch change.Side {
+	case sideBuy:
+		bid = true
+		side = bids
+		otherSide = asks
+	case sideSell:
+		bid = false
+		side = asks
+		otherSide = bids
+	default:
+		return
+	}
+	if size.IsPositive() {
+		side.Put(price, size)
+		removeKeys := []decimal.Decimal{}
+		it := otherSide.Iterator()
+		if bid {
+			for it.Next() {
+				key := it.Key().(decimal.Decimal)
+				if key.LessThanOrEqual(price) {
+					removeKeys = append(removeKeys, key)
 				}
 			}
-			for _, key := range removeKeys {
-				otherSide.Remove(key)
-			}
-		} else if size.IsZero() {
-			side.Remove(price)
-			otherSide.Remove(price)
 		} else {
-			log.Printf("Warning: negative price change")
-			side.Remove(price)
-			otherSide.Remove(price)
+			it.End()
+			for it.Prev() {
+				key := it.Key().(decimal.Decimal)
+				if key.GreaterThanOrEqual(price) {
+					removeKeys = append(removeKeys, key)
+				}
+			}
 		}
-	}
-	if debugOrderBook {
-		subscription.printOrderBook()
+		for _, key := range removeKeys {
+			otherSide.Remove(key)
+		}
+	} else if size.IsZero() {
+		side.Remove(price)
+		otherSide.Remove(price)
+	} else {
+		log.Printf("Warning: negative price change")
+		side.Remove(price)
+		otherSide.Remove(price)
 	}
 }
 
@@ -278,7 +503,7 @@ func (s *tradingSystem) onLastTradePrice(message gamma.BookMessage, subscription
 		return
 	}
 	event := priceEvent{
-		timestamp: time.Now(),
+		timestamp: getTime(),
 		price: price,
 		size: size,
 	}
@@ -292,40 +517,291 @@ func (s *tradingSystem) onLastTradePrice(message gamma.BookMessage, subscription
 }
 
 func (s *tradingSystem) processTrigger(price decimal.Decimal, side string, subscription *marketSubscription) {
-	trigger, exists := commons.FindPointer(s.triggers, func (t triggerData) bool {
-		return t.slug == subscription.slug
-	})
+	trigger, exists := s.getTrigger(subscription.slug)
+	if !exists {
+		log.Printf("Warning: received a book message without a matching trigger: subscription.slug = %s", subscription.slug)
+		return
+	}
 	if trigger.triggered {
 		if debugTrigger {
 			log.Printf("Trigger for \"%s\" had already been triggered", trigger.slug)
 		}
 		return
 	}
-	if !exists {
-		log.Printf("Warning: received a book message without a matching trigger: subscription.slug = %s", subscription.slug)
+	if trigger.pending {
+		if debugTrigger {
+			log.Printf("Trigger for \"%s\" is waiting for an order to be filled", trigger.slug)
+		}
+		return
+	}
+	if subscription.integrity.stale {
+		log.Printf("Not evaluating trigger for \"%s\", the order book is stale", trigger.slug)
+		return
 	}
 	definition := trigger.trigger
-	takeProfit := definition.TakeProfit
-	sellPosition := func (limit decimal.Decimal) {
-		err := postOrder(trigger.slug, trigger.assetID, model.SELL, trigger.size, limit, subscription.negRisk, 0)
-		if err != nil {
-			log.Printf("Failed to execute order: %v", err)
-			return
-		}
-		trigger.triggered = true
+	if definition.TrailingStop != nil && price.GreaterThan(trigger.highWater) {
+		trigger.highWater = price
 	}
-	if takeProfit != nil && price.GreaterThanOrEqual(takeProfit.Decimal) && side == sideBuy {
+	takeProfit := definition.TakeProfit
+	stopLoss := trigger.getStopLoss()
+	if takeProfit != nil && !*definition.Bracket && price.GreaterThanOrEqual(takeProfit.Decimal) && side == sideBuy {
 		log.Printf("Take profit has been triggered for \"%s\" at %s", trigger.slug, price)
-		go sellPosition(definition.TakeProfitLimit.Decimal)
-	} else if price.LessThanOrEqual(definition.StopLoss.Decimal) && side == sideSell {
-		log.Printf("Stop-loss has been triggered for \"%s\" at %s", trigger.slug, price)
-		go sellPosition(definition.StopLossLimit.Decimal)
+		trigger.pending = true
+		s.sellPosition(trigger, subscription.negRisk, definition.TakeProfitLimit.Decimal)
+	} else if stopLoss != nil && price.LessThanOrEqual(*stopLoss) && side == sideSell {
+		log.Printf("Stop-loss has been triggered for \"%s\" at %s (stop = %s)", trigger.slug, price, *stopLoss)
+		limit := trigger.getStopLossLimit(*stopLoss, subscription)
+		trigger.pending = true
+		s.exitPosition(trigger, subscription.negRisk, limit)
 	} else {
 		if debugTrigger {
 			format := "No action required: takeProfit = %s, takeProfitLimit = %s, stopLoss = %s, stopLossLimit = %s, size = %s, price = %s, side = %s"
-			log.Printf(format, takeProfit, definition.TakeProfitLimit, definition.StopLoss, definition.StopLossLimit, trigger.size, price, side)
+			log.Printf(format, takeProfit, definition.TakeProfitLimit, stopLoss, definition.StopLossLimit, trigger.size, price, side)
+		}
+	}
+}
+
+func (s *tradingSystem) checkTimeExit(subscription *marketSubscription) {
+	trigger, exists := s.getTrigger(subscription.slug)
+	if !exists || trigger.triggered || trigger.pending || trigger.exitTime == nil || getTime().Before(*trigger.exitTime) {
+		return
+	}
+	if subscription.integrity.stale {
+		log.Printf("Delaying time exit for \"%s\", the order book is stale", trigger.slug)
+		return
+	}
+	bestBid, exists := getBestBid(subscription.bids)
+	if !exists {
+		log.Printf("Unable to perform time exit for \"%s\", there are no bids", trigger.slug)
+		return
+	}
+	limit := getExitLimit(bestBid, trigger.trigger.ExitSlippage.Decimal)
+	log.Printf("Time exit has been triggered for \"%s\" at %s", trigger.slug, commons.GetTimeString(*trigger.exitTime))
+	trigger.pending = true
+	s.exitPosition(trigger, subscription.negRisk, limit)
+}
+
+func (s *tradingSystem) sellPosition(trigger *triggerData, negRisk bool, limit decimal.Decimal) {
+	slug := trigger.slug
+	assetID := trigger.assetID
+	size := trigger.size
+	onUpdate := s.getTriggerCallback(trigger)
+	s.execute(func () {
+		orderID, err := s.orders.submit(slug, assetID, model.SELL, size, limit, negRisk, 0, onUpdate)
+		s.loop.post(func () {
+			if err != nil {
+				log.Printf("Failed to execute order: %v", err)
+				trigger.pending = false
+				return
+			}
+			trigger.orderID = orderID
+			s.saveState(true)
+		})
+	})
+}
+
+func (s *tradingSystem) exitPosition(trigger *triggerData, negRisk bool, limit decimal.Decimal) {
+	if trigger.bracketPending {
+		trigger.exitLimit = &limit
+		trigger.exitNegRisk = negRisk
+		return
+	}
+	if trigger.bracketOrderID == "" {
+		s.sellPosition(trigger, negRisk, limit)
+		return
+	}
+	bracketOrderID := trigger.bracketOrderID
+	trigger.exitLimit = &limit
+	trigger.exitNegRisk = negRisk
+	s.execute(func () {
+		err := s.orders.cancel(bracketOrderID)
+		if err != nil {
+			s.loop.post(func () {
+				log.Printf("Failed to cancel bracket order %s: %v", bracketOrderID, err)
+				trigger.pending = false
+				trigger.exitLimit = nil
+			})
+		}
+	})
+}
+
+func (s *tradingSystem) placeBracketOrders() {
+	for _, trigger := range s.triggers {
+		definition := trigger.trigger
+		if !*definition.Bracket || trigger.triggered || trigger.pending || trigger.bracketOrderID != "" || trigger.bracketPending {
+			continue
+		}
+		market, exists := commons.Find(s.markets, func (m gamma.Market) bool {
+			return m.Slug == trigger.slug
+		})
+		if !exists {
+			log.Printf("Unable to place bracket order for \"%s\", market not found", trigger.slug)
+			continue
+		}
+		s.placeBracketOrder(trigger, market.NegRisk)
+	}
+}
+
+func (s *tradingSystem) placeBracketOrder(trigger *triggerData, negRisk bool) {
+	slug := trigger.slug
+	assetID := trigger.assetID
+	size := trigger.size
+	limit := trigger.trigger.TakeProfitLimit.Decimal
+	onUpdate := s.getBracketCallback(trigger)
+	trigger.bracketPending = true
+	s.execute(func () {
+		orderID, err := s.orders.submit(slug, assetID, model.SELL, size, limit, negRisk, 0, onUpdate)
+		s.loop.post(func () {
+			trigger.bracketPending = false
+			exitLimit := trigger.exitLimit
+			trigger.exitLimit = nil
+			if err != nil {
+				log.Printf("Failed to place bracket order for \"%s\": %v", slug, err)
+				if exitLimit != nil {
+					s.sellPosition(trigger, trigger.exitNegRisk, *exitLimit)
+				}
+				return
+			}
+			if !trigger.triggered {
+				trigger.bracketOrderID = orderID
+			}
+			log.Printf("Placed bracket order for \"%s\" at %s", slug, limit)
+			if exitLimit != nil && trigger.bracketOrderID != "" {
+				s.exitPosition(trigger, trigger.exitNegRisk, *exitLimit)
+			}
+			s.saveState(true)
+		})
+	})
+}
+
+func (s *tradingSystem) getBracketCallback(trigger *triggerData) func (order managedOrder) {
+	return func (order managedOrder) {
+		switch order.state {
+		case orderFilled:
+			log.Printf("Bracket order for \"%s\" has been filled", trigger.slug)
+			trigger.triggered = true
+			trigger.pending = false
+			trigger.bracketOrderID = ""
+			trigger.exitLimit = nil
+			trigger.replaceBracket = false
+		case orderCanceled:
+			trigger.size = trigger.size.Sub(order.sizeMatched)
+			trigger.triggered = !trigger.size.IsPositive()
+			trigger.bracketOrderID = ""
+			replaceBracket := trigger.replaceBracket
+			trigger.replaceBracket = false
+			if trigger.exitLimit != nil {
+				limit := *trigger.exitLimit
+				trigger.exitLimit = nil
+				if trigger.triggered {
+					trigger.pending = false
+				} else {
+					s.sellPosition(trigger, trigger.exitNegRisk, limit)
+				}
+			} else if replaceBracket && !trigger.triggered {
+				if s.orders.isFrozen() {
+					log.Printf("Not replacing bracket order for \"%s\", trading is frozen", trigger.slug)
+				} else {
+					s.placeBracketOrders()
+				}
+			}
+		}
+		s.saveState(true)
+	}
+}
+
+func (t *triggerData) getStopLoss() *decimal.Decimal {
+	definition := t.trigger
+	var stopLoss *decimal.Decimal
+	if definition.StopLoss != nil {
+		stopLoss = &definition.StopLoss.Decimal
+	}
+	if definition.TrailingStop != nil && t.highWater.IsPositive() {
+		trailingStop := t.highWater.Sub(definition.TrailingStop.Decimal)
+		if stopLoss == nil || trailingStop.GreaterThan(*stopLoss) {
+			stopLoss = &trailingStop
+		}
+	}
+	return stopLoss
+}
+
+func (t *triggerData) getStopLossLimit(stopLoss decimal.Decimal, subscription *marketSubscription) decimal.Decimal {
+	definition := t.trigger
+	if definition.StopLoss != nil && stopLoss.Equal(definition.StopLoss.Decimal) {
+		return definition.StopLossLimit.Decimal
+	}
+	bestBid, exists := getBestBid(subscription.bids)
+	if !exists || bestBid.GreaterThan(stopLoss) {
+		bestBid = stopLoss
+	}
+	return getExitLimit(bestBid, definition.ExitSlippage.Decimal)
+}
+
+func getTriggerExitTime(trigger Trigger, market *gamma.Market) *time.Time {
+	var exitTime *time.Time
+	if trigger.ExitTime != nil {
+		exitTime = trigger.ExitTime
+	}
+	if trigger.ExitBeforeEnd != nil && market != nil {
+		endDate, err := commons.ParseTime(market.EndDate)
+		if err != nil {
+			log.Printf("Failed to parse end date of \"%s\": %v", market.Slug, err)
+		} else {
+			beforeEnd := endDate.Add(- time.Duration(*trigger.ExitBeforeEnd) * time.Hour)
+			if exitTime == nil || beforeEnd.Before(*exitTime) {
+				exitTime = &beforeEnd
+			}
 		}
 	}
+	return exitTime
+}
+
+func getBestBid(bids *treemap.Map) (decimal.Decimal, bool) {
+	key, _ := bids.Max()
+	if key == nil {
+		return decimal.Zero, false
+	}
+	return key.(decimal.Decimal), true
+}
+
+func getExitLimit(price, slippage decimal.Decimal) decimal.Decimal {
+	limitMin := decimalConstant("0.01")
+	return decimal.Max(price.Sub(slippage), limitMin)
+}
+
+func (s *tradingSystem) getTriggerCallback(trigger *triggerData) func (order managedOrder) {
+	return func (order managedOrder) {
+		switch order.state {
+		case orderFilled:
+			trigger.triggered = true
+			trigger.pending = false
+		case orderCanceled:
+			trigger.size = trigger.size.Sub(order.sizeMatched)
+			trigger.triggered = !trigger.size.IsPositive()
+			trigger.pending = false
+		}
+		s.saveState(true)
+	}
+}
+
+func (s *tradingSystem) execute(action func ()) {
+	if s.replay || s.paper != nil {
+		action()
+	} else {
+		go action()
+	}
+}
+
+func (s *tradingSystem) getTrigger(slug string) (*triggerData, bool) {
+	return commons.Find(s.triggers, func (t *triggerData) bool {
+		return t.slug == slug
+	})
+}
+
+func (s *tradingSystem) getMarketBySlug(slug string) (*gamma.Market, bool) {
+	return commons.FindPointer(s.markets, func (m gamma.Market) bool {
+		return m.Slug == slug
+	})
 }
 
 func (s *tradingSystem) getMarket(conditionID string) (gamma.Market, bool) {
@@ -341,11 +817,12 @@ func (s *tradingSystem) getMarket(conditionID string) (gamma.Market, bool) {
 
 func (s *tradingSystem) getSubscription(message gamma.BookMessage) (marketSubscription, bool) {
 	conditionID := message.Market
-	assetID := message.AssetID
 	subscription, exists := s.subscriptions[conditionID]
 	if !exists {
-		if assetID == "" {
-			log.Printf("Warning: invalid asset ID in subscription %s", conditionID)
+		assetID, exists := s.getSubscribedAsset(message)
+		if !exists {
+			log.Printf("Warning: no subscribed asset ID in message for condition ID %s", conditionID)
+			return marketSubscription{}, false
 		}
 		market, exists := s.getMarket(conditionID)
 		if !exists {
@@ -360,11 +837,25 @@ func (s *tradingSystem) getSubscription(message gamma.BookMessage) (marketSubscr
 			prices: deque.Deque[priceEvent]{},
 			asks: treemap.NewWith(decimalComparator),
 			bids: treemap.NewWith(decimalComparator),
+			integrity: bookIntegrity{},
 		}
+		s.restorePrices(&subscription)
 	}
 	return subscription, true
 }
 
+func (s *tradingSystem) getSubscribedAsset(message gamma.BookMessage) (string, bool) {
+	if s.assets[message.AssetID] {
+		return message.AssetID, true
+	}
+	for _, change := range message.PriceChanges {
+		if s.assets[change.AssetID] {
+			return change.AssetID, true
+		}
+	}
+	return "", false
+}
+
 func (s *marketSubscription) add(event priceEvent) {
 	priceMin := decimal.Zero
 	priceMax := decimalConstant("1.0")
@@ -373,8 +864,8 @@ func (s *marketSubscription) add(event priceEvent) {
 		return
 	}
 	s.prices.PushBack(event)
-	duration := time.Duration(*configuration.Data.BufferTimeSpan) * time.Second
-	now := time.Now()
+	duration := time.Duration(*getConfiguration().Data.BufferTimeSpan) * time.Second
+	now := getTime()
 	for s.prices.Len() > 0 {
 		price := s.prices.Front()
 		age := now.Sub(price.timestamp)
@@ -441,7 +932,7 @@ func getPriceSize(priceString string, sizeString string) (decimal.Decimal, decim
 func getEventMarkets() ([]gamma.Market, map[string]string, error) {
 	markets := []gamma.Market{}
 	eventSlugMap := map[string]string{}
-	for _, tagSlug := range configuration.Data.TagSlugs {
+	for _, tagSlug := range getConfiguration().Data.TagSlugs {
 		events, err := gamma.GetEvents(&tagSlug)
 		if err != nil {
 			return nil, nil, err
@@ -455,7 +946,7 @@ func getEventMarkets() ([]gamma.Market, map[string]string, error) {
 					continue
 				}
 				volume := decimal.NewFromFloat(market.Volume24Hr)
-				if volume.LessThan(configuration.Data.MinVolume.Decimal) {
+				if volume.LessThan(getConfiguration().Data.MinVolume.Decimal) {
 					continue
 				}
 				exists := commons.ContainsFunc(markets, func (m gamma.Market) bool {
@@ -36,9 +36,9 @@ func showEventTags(slug string) {
 
 func showRelatedTags(tag string) {
 	loadConfiguration()
-	database := newDatabaseClient()
+	database := newStorage()
 	defer database.close()
-	historyData := database.getTagsOnly()
+	historyData := database.getTagsOnly(getHistoryFidelity())
 	countMap := map[string]tagCount{}
 	total := 0
 	for _, history := range historyData {
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"log"
+	"slices"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/gammazero/deque"
+)
+
+const (
+	resolutionHour = "hour"
+	resolutionTick = "tick"
+)
+
+type backtestTickType int
+
+const (
+	tickTrade backtestTickType = iota
+	tickPriceChange
+)
+
+type tickStrategy interface {
+	onTick(backtest *backtestData, tick backtestTick)
+}
+
+type backtestTick struct {
+	tickType backtestTickType
+	timestamp time.Time
+	slug string
+	price float64
+	size float64
+	buy bool
+	bestBid float64
+	bestAsk float64
+	gap bool
+}
+
+func loadBacktestTicks(start, end time.Time) []backtestTick {
+	database := newStorage()
+	defer database.close()
+	slugs := map[string]string{}
+	for _, market := range database.getMarkets() {
+		slugs[market.AssetID] = market.Slug
+	}
+	ticks := []backtestTick{}
+	for _, trade := range database.getLastTradePricesInRange(start, end) {
+		slug, exists := slugs[trade.AssetID]
+		if !exists {
+			continue
+		}
+		tick := backtestTick{
+			tickType: tickTrade,
+			timestamp: trade.ServerTime,
+			slug: slug,
+			price: decimal128ToFloat(trade.Price),
+			size: decimal128ToFloat(trade.Size),
+			buy: trade.Buy,
+		}
+		ticks = append(ticks, tick)
+	}
+	for _, change := range database.getPriceChangesInRange(start, end) {
+		slug, exists := slugs[change.AssetID]
+		if !exists {
+			continue
+		}
+		tick := backtestTick{
+			tickType: tickPriceChange,
+			timestamp: change.ServerTime,
+			slug: slug,
+			price: decimal128ToFloat(change.Price),
+			size: decimal128ToFloat(change.Size),
+			buy: change.Buy,
+			bestBid: decimal128ToFloat(change.BestBid),
+			bestAsk: decimal128ToFloat(change.BestAsk),
+		}
+		ticks = append(ticks, tick)
+	}
+	slices.SortStableFunc(ticks, func (a, b backtestTick) int {
+		return a.timestamp.Compare(b.timestamp)
+	})
+	gaps := database.getGapsInRange(start, end)
+	flagGapTicks(ticks, gaps, slugs)
+	log.Printf("Loaded %d ticks and %d collection gaps", len(ticks), len(gaps))
+	return ticks
+}
+
+func flagGapTicks(ticks []backtestTick, gaps []CollectionGap, slugs map[string]string) {
+	for _, gap := range gaps {
+		gapSlugs := map[string]bool{}
+		for _, assetID := range gap.AssetIDs {
+			slug, exists := slugs[assetID]
+			if exists {
+				gapSlugs[slug] = true
+			}
+		}
+		first, _ := slices.BinarySearchFunc(ticks, gap.Disconnected, func (tick backtestTick, timestamp time.Time) int {
+			return tick.timestamp.Compare(timestamp)
+		})
+		for i := first; i < len(ticks) && len(gapSlugs) > 0; i++ {
+			slug := ticks[i].slug
+			if gapSlugs[slug] {
+				ticks[i].gap = true
+				delete(gapSlugs, slug)
+			}
+		}
+	}
+}
+
+func executeTickBacktest(
+	strategy tickStrategy,
+	start time.Time,
+	end time.Time,
+	initialCash float64,
+	fill fillModel,
+	data *backtestDataSet,
+) backtestResult {
+	backtest := newBacktestData(start, initialCash, fill, data)
+	backtest.tickPrices = map[string]backtestTickPrice{}
+	nextHour := commons.GetHourTimestamp(start).Add(time.Hour)
+	step := func () {
+		backtest.now = nextHour
+		backtest.resolveMarkets()
+		backtest.updateStats()
+		nextHour = nextHour.Add(time.Hour)
+	}
+	first, _ := slices.BinarySearchFunc(data.ticks, start, func (tick backtestTick, timestamp time.Time) int {
+		return tick.timestamp.Compare(timestamp)
+	})
+	for _, tick := range data.ticks[first:] {
+		if !tick.timestamp.Before(end) {
+			break
+		}
+		for !tick.timestamp.Before(nextHour) {
+			step()
+		}
+		backtest.now = tick.timestamp
+		if tick.tickType == tickTrade {
+			backtest.tickPrices[tick.slug] = backtestTickPrice{
+				price: tick.price,
+				timestamp: tick.timestamp,
+			}
+		}
+		strategy.onTick(&backtest, tick)
+	}
+	for nextHour.Before(end) {
+		step()
+	}
+	backtest.now = end
+	return getBacktestResult(&backtest, start, end, initialCash)
+}
+
+func (s *jumpStrategy) onTick(backtest *backtestData, tick backtestTick) {
+	if tick.tickType == tickTrade && s.isIncluded(backtest, tick.slug) {
+		exists := commons.ContainsFunc(backtest.positions, func (p backtestPosition) bool {
+			return p.slug == tick.slug
+		})
+		prices, windowExists := s.priceWindows[tick.slug]
+		if !windowExists {
+			prices = &deque.Deque[priceSample]{}
+			s.priceWindows[tick.slug] = prices
+		}
+		if tick.gap {
+			prices.Clear()
+		}
+		sample := priceSample{
+			timestamp: tick.timestamp,
+			price: tick.price,
+		}
+		prices.PushBack(sample)
+		for prices.Len() > 0 && tick.timestamp.Sub(prices.Front().timestamp) > time.Hour {
+			prices.PopFront()
+		}
+		firstPrice := prices.Front().price
+		if !exists && firstPrice <= s.threshold1 && tick.price >= s.threshold2 && tick.price < s.threshold3 {
+			_ = backtest.openPosition(tick.slug, sideNo, s.positionSize)
+		}
+	}
+	s.closeJumpPositions(backtest)
+}
+
+func (s *jumpStrategy) isIncluded(backtest *backtestData, slug string) bool {
+	included, exists := s.includedMarkets[slug]
+	if exists {
+		return included
+	}
+	included = false
+	market, exists := backtest.historyMap[slug]
+	if exists {
+		included = len(s.includeTags) == 0
+		for _, tag := range market.Tags {
+			if commons.Contains(s.excludeTags, tag) {
+				included = false
+				break
+			}
+			if commons.Contains(s.includeTags, tag) {
+				included = true
+			}
+		}
+	}
+	s.includedMarkets[slug] = included
+	return included
+}
\ No newline at end of file
@@ -13,41 +13,53 @@ import (
 )
 
 const (
-	tradesDownloadThrottle = 100
 	fileExistsCheck = false
 )
 
 func downloadTrades(slug, directory string) {
 	outputDirectory := filepath.Join(directory, slug)
 	commons.CreateDirectory(outputDirectory)
-	event, err := gamma.GetEventBySlug(slug)
-	if err != nil {
-		return
-	}
-	for _, market := range event.Markets {
-		yesID, err := getCLOBTokenID(market, true)
+	scheduler := newDownloadScheduler()
+	markets := []gamma.Market{}
+	err := scheduler.request(slug, func () error {
+		event, err := gamma.GetEventBySlug(slug)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		downloadMarketTrades(market.Slug, market.ConditionID, yesID, outputDirectory)
+		markets = event.Markets
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Failed to get event %s: %v", slug, err)
+	}
+	for _, market := range markets {
+		scheduler.submit(market.Slug, func () (bool, error) {
+			yesID, err := getCLOBTokenID(market, true)
+			if err != nil {
+				return false, newPermanentError("%v", err)
+			}
+			return downloadMarketTrades(scheduler, market.Slug, market.ConditionID, yesID, outputDirectory)
+		})
 	}
+	scheduler.printSummary()
 }
 
-func downloadMarketTrades(slug, conditionID, yesID, directory string) {
+func downloadMarketTrades(scheduler *downloadScheduler, slug, conditionID, yesID, directory string) (bool, error) {
 	buyFileName := fmt.Sprintf("%s-buy.csv", slug)
 	sellFileName := fmt.Sprintf("%s-sell.csv", slug)
 	buyOutputPath := filepath.Join(directory, buyFileName)
 	sellOutputPath := filepath.Join(directory, sellFileName)
 	if fileExistsCheck && commons.FileExists(buyOutputPath) {
 		log.Printf("%s already exists, skipping\n", buyOutputPath)
-		return
+		return true, nil
 	}
 	buys := []gamma.Trade{}
 	sells := []gamma.Trade{}
 	for offset := 0; offset <= gamma.TradesAPIOffsetLimit; offset += gamma.TradesAPILimit {
+		scheduler.throttle()
 		trades, err := gamma.GetTrades(conditionID, offset)
 		if err != nil {
-			return
+			return false, err
 		}
 		for _, trade := range trades {
 			if trade.Asset != yesID {
@@ -69,10 +81,10 @@ func downloadMarketTrades(slug, conditionID, yesID, directory string) {
 		if len(trades) < gamma.TradesAPILimit {
 			break
 		}
-		time.Sleep(time.Duration(tradesDownloadThrottle) * time.Millisecond)
 	}
 	writeTradesToFile(buys, buyOutputPath)
 	writeTradesToFile(sells, sellOutputPath)
+	return false, nil
 }
 
 func writeTradesToFile(trades []gamma.Trade, path string) {
@@ -0,0 +1,420 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/encratite/commons"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+const (
+	walSegmentExtension = ".wal"
+	walCheckpointFile = "checkpoint.json"
+	walDeadLetterFile = "dead-letter.bson"
+	walSegmentSizeLimit = 16 * 1024 * 1024
+	walRotateInterval = 10
+	walBatchSize = 500
+	walDuplicateKeyCode = 11000
+)
+
+type writeAheadLog struct {
+	mutex sync.Mutex
+	directory string
+	file *os.File
+	segment int
+	size int
+	opened time.Time
+	collections map[string]*mongo.Collection
+}
+
+type walRecord struct {
+	Collection string `bson:"collection"`
+	Document bson.Raw `bson:"document"`
+}
+
+type walCheckpoint struct {
+	Segment int `json:"segment"`
+	Offset int64 `json:"offset"`
+}
+
+func newWriteAheadLog(directory string, collections []*mongo.Collection) *writeAheadLog {
+	err := os.MkdirAll(directory, 0755)
+	if err != nil {
+		log.Fatalf("Failed to create write-ahead log directory %s: %v", directory, err)
+	}
+	w := &writeAheadLog{
+		directory: directory,
+		file: nil,
+		segment: 0,
+		size: 0,
+		opened: time.Time{},
+		collections: map[string]*mongo.Collection{},
+	}
+	for _, collection := range collections {
+		w.collections[collection.Name()] = collection
+	}
+	segments := w.getSegments()
+	if len(segments) > 0 {
+		log.Printf("Found %d write-ahead log segments that have not been written to the database yet", len(segments))
+	}
+	w.segment = w.getLastSegment(segments)
+	go w.drain()
+	return w
+}
+
+func (w *writeAheadLog) append(collection *mongo.Collection, document any) {
+	data, err := bson.Marshal(document)
+	if err != nil {
+		log.Printf("Failed to serialize %s document: %v", collection.Name(), err)
+		return
+	}
+	record := walRecord{
+		Collection: collection.Name(),
+		Document: data,
+	}
+	data, err = bson.Marshal(record)
+	if err != nil {
+		log.Printf("Failed to serialize write-ahead log record: %v", err)
+		return
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil || w.size >= walSegmentSizeLimit {
+		w.rotate()
+	}
+	if w.file == nil {
+		return
+	}
+	_, err = w.file.Write(data)
+	if err != nil {
+		log.Printf("Failed to write to write-ahead log segment %s: %v", w.file.Name(), err)
+		return
+	}
+	w.size += len(data)
+}
+
+func (w *writeAheadLog) rotate() {
+	if w.file != nil {
+		w.closeSegment()
+	}
+	w.segment++
+	path := w.getSegmentPath(w.segment)
+	file, err := os.OpenFile(path, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Failed to create write-ahead log segment %s: %v", path, err)
+		return
+	}
+	w.file = file
+	w.size = 0
+	w.opened = time.Now()
+}
+
+func (w *writeAheadLog) closeSegment() {
+	err := w.file.Sync()
+	if err != nil {
+		log.Printf("Failed to sync write-ahead log segment %s: %v", w.file.Name(), err)
+	}
+	w.file.Close()
+	w.file = nil
+}
+
+func (w *writeAheadLog) sync() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file != nil {
+		w.closeSegment()
+	}
+}
+
+func (w *writeAheadLog) getSealedSegments() []int {
+	w.mutex.Lock()
+	if w.file != nil && time.Since(w.opened) >= time.Duration(walRotateInterval) * time.Second {
+		w.closeSegment()
+	}
+	current := -1
+	if w.file != nil {
+		current = w.segment
+	}
+	w.mutex.Unlock()
+	segments := []int{}
+	for _, segment := range w.getSegments() {
+		if segment != current {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func (w *writeAheadLog) getSegments() []int {
+	entries, err := os.ReadDir(w.directory)
+	if err != nil {
+		log.Printf("Failed to read write-ahead log directory %s: %v", w.directory, err)
+		return nil
+	}
+	segments := []int{}
+	for _, entry := range entries {
+		name, found := strings.CutSuffix(entry.Name(), walSegmentExtension)
+		if !found {
+			continue
+		}
+		segment, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	slices.Sort(segments)
+	return segments
+}
+
+func (w *writeAheadLog) getLastSegment(segments []int) int {
+	segment := w.loadCheckpoint().Segment
+	if len(segments) > 0 {
+		segment = max(segment, segments[len(segments) - 1])
+	}
+	return max(segment, 0)
+}
+
+func (w *writeAheadLog) getSegmentPath(segment int) string {
+	name := fmt.Sprintf("%020d%s", segment, walSegmentExtension)
+	return filepath.Join(w.directory, name)
+}
+
+func (w *writeAheadLog) drain() {
+	backoff := newReconnectBackoff()
+	for {
+		segments := w.getSealedSegments()
+		for _, segment := range segments {
+			w.drainSegment(segment, backoff)
+		}
+		time.Sleep(time.Duration(walRotateInterval) * time.Second)
+	}
+}
+
+func (w *writeAheadLog) drainSegment(segment int, backoff *reconnectBackoff) {
+	path := w.getSegmentPath(segment)
+	checkpoint := w.loadCheckpoint()
+	offset := int64(0)
+	if checkpoint.Segment == segment {
+		offset = checkpoint.Offset
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open write-ahead log segment %s: %v", path, err)
+		return
+	}
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		file.Close()
+		log.Printf("Failed to seek in write-ahead log segment %s: %v", path, err)
+		return
+	}
+	reader := bufio.NewReader(file)
+	for {
+		records, size, err := readWALRecords(reader)
+		if len(records) > 0 {
+			for !w.insertRecords(records) {
+				backoff.wait()
+			}
+			backoff.connect()
+			offset += size
+			w.saveCheckpoint(segment, offset)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("Warning: discarding the remainder of write-ahead log segment %s: %v", path, err)
+			break
+		}
+	}
+	file.Close()
+	err = os.Remove(path)
+	if err != nil {
+		log.Printf("Failed to remove write-ahead log segment %s: %v", path, err)
+		return
+	}
+	w.removeCheckpoint(segment)
+}
+
+func readWALRecords(reader *bufio.Reader) ([]walRecord, int64, error) {
+	records := []walRecord{}
+	size := int64(0)
+	for len(records) < walBatchSize {
+		data, err := readBSONDocument(reader)
+		if err != nil {
+			return records, size, err
+		}
+		var record walRecord
+		err = bson.Unmarshal(data, &record)
+		if err != nil {
+			return records, size, err
+		}
+		records = append(records, record)
+		size += int64(len(data))
+	}
+	return records, size, nil
+}
+
+func (w *writeAheadLog) insertRecords(records []walRecord) bool {
+	documents := map[string][]any{}
+	for _, record := range records {
+		documents[record.Collection] = append(documents[record.Collection], record.Document)
+	}
+	for name, collectionDocuments := range documents {
+		collection, exists := w.collections[name]
+		if !exists {
+			log.Printf("Warning: discarding %d write-ahead log records of unknown collection %s", len(collectionDocuments), name)
+			continue
+		}
+		ctx, cancel := getDatabaseContext()
+		unordered := options.InsertMany().SetOrdered(false)
+		_, err := collection.InsertMany(ctx, collectionDocuments, unordered)
+		cancel()
+		if err == nil || isDuplicateKeyErrorOnly(err) {
+			continue
+		}
+		if isTransientWriteError(err) {
+			log.Printf("Failed to insert write-ahead log records into %s: %v", name, err)
+			return false
+		}
+		w.writeDeadLetters(name, getRejectedDocuments(err, collectionDocuments), err)
+	}
+	return true
+}
+
+func (w *writeAheadLog) writeDeadLetters(name string, documents []any, reason error) {
+	path := filepath.Join(w.directory, walDeadLetterFile)
+	log.Printf("Warning: moving %d write-ahead log records of %s to %s: %v", len(documents), name, path, reason)
+	sendAlert(alertWarning, "", "Moved %d write-ahead log records of %s to %s: %v", len(documents), name, path, reason)
+	file, err := os.OpenFile(path, os.O_CREATE | os.O_WRONLY | os.O_APPEND, 0644)
+	if err != nil {
+		log.Printf("Failed to open write-ahead log dead-letter file %s: %v", path, err)
+		return
+	}
+	defer file.Close()
+	for _, document := range documents {
+		record := walRecord{
+			Collection: name,
+			Document: document.(bson.Raw),
+		}
+		data, err := bson.Marshal(record)
+		if err != nil {
+			log.Printf("Failed to serialize write-ahead log dead letter: %v", err)
+			continue
+		}
+		_, err = file.Write(data)
+		if err != nil {
+			log.Printf("Failed to write to write-ahead log dead-letter file %s: %v", path, err)
+			return
+		}
+	}
+	err = file.Sync()
+	if err != nil {
+		log.Printf("Failed to sync write-ahead log dead-letter file %s: %v", path, err)
+	}
+}
+
+func getRejectedDocuments(err error, documents []any) []any {
+	var bulkError mongo.BulkWriteException
+	if !errors.As(err, &bulkError) || len(bulkError.WriteErrors) == 0 {
+		return documents
+	}
+	rejected := []any{}
+	for _, writeError := range bulkError.WriteErrors {
+		if writeError.Code != walDuplicateKeyCode && writeError.Index >= 0 && writeError.Index < len(documents) {
+			rejected = append(rejected, documents[writeError.Index])
+		}
+	}
+	return rejected
+}
+
+func isTransientWriteError(err error) bool {
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) || errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+	var labeledError mongo.LabeledError
+	if errors.As(err, &labeledError) && (labeledError.HasErrorLabel("RetryableWriteError") || labeledError.HasErrorLabel("TransientTransactionError")) {
+		return true
+	}
+	var bulkError mongo.BulkWriteException
+	return errors.As(err, &bulkError) && bulkError.WriteConcernError != nil
+}
+
+func isDuplicateKeyErrorOnly(err error) bool {
+	var bulkError mongo.BulkWriteException
+	if !errors.As(err, &bulkError) || bulkError.WriteConcernError != nil || len(bulkError.WriteErrors) == 0 {
+		return false
+	}
+	for _, writeError := range bulkError.WriteErrors {
+		if writeError.Code != walDuplicateKeyCode {
+			return false
+		}
+	}
+	return true
+}
+
+func (w *writeAheadLog) loadCheckpoint() walCheckpoint {
+	checkpoint := walCheckpoint{
+		Segment: -1,
+		Offset: 0,
+	}
+	path := filepath.Join(w.directory, walCheckpointFile)
+	if !commons.FileExists(path) {
+		return checkpoint
+	}
+	bytes, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read write-ahead log checkpoint %s: %v", path, err)
+		return checkpoint
+	}
+	err = json.Unmarshal(bytes, &checkpoint)
+	if err != nil {
+		log.Printf("Failed to deserialize write-ahead log checkpoint %s: %v", path, err)
+	}
+	return checkpoint
+}
+
+func (w *writeAheadLog) removeCheckpoint(segment int) {
+	if w.loadCheckpoint().Segment != segment {
+		return
+	}
+	path := filepath.Join(w.directory, walCheckpointFile)
+	err := os.Remove(path)
+	if err != nil {
+		log.Printf("Failed to remove write-ahead log checkpoint %s: %v", path, err)
+	}
+}
+
+func (w *writeAheadLog) saveCheckpoint(segment int, offset int64) {
+	checkpoint := walCheckpoint{
+		Segment: segment,
+		Offset: offset,
+	}
+	bytes, err := json.Marshal(checkpoint)
+	if err != nil {
+		log.Printf("Failed to serialize write-ahead log checkpoint: %v", err)
+		return
+	}
+	path := filepath.Join(w.directory, walCheckpointFile)
+	temporaryPath := path + ".tmp"
+	commons.WriteFile(temporaryPath, string(bytes))
+	err = os.Rename(temporaryPath, path)
+	if err != nil {
+		log.Printf("Failed to replace write-ahead log checkpoint %s: %v", path, err)
+	}
+}
\ No newline at end of file
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/encratite/commons"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+)
+
+func TestWALRejectedDocuments(t *testing.T) {
+	documents := []any{}
+	for i := range 3 {
+		document, err := bson.Marshal(bson.D{{Key: "index", Value: i}})
+		if err != nil {
+			t.Fatalf("Failed to serialize document: %v", err)
+		}
+		documents = append(documents, bson.Raw(document))
+	}
+	bulkError := mongo.BulkWriteException{
+		WriteConcernError: nil,
+		WriteErrors: []mongo.BulkWriteError{
+			{WriteError: mongo.WriteError{Index: 0, Code: walDuplicateKeyCode}},
+			{WriteError: mongo.WriteError{Index: 2, Code: 121}},
+		},
+	}
+	if isTransientWriteError(bulkError) {
+		t.Errorf("document validation errors should be permanent")
+	}
+	rejected := getRejectedDocuments(bulkError, documents)
+	if len(rejected) != 1 || !equalDocuments(rejected[0], documents[2]) {
+		t.Fatalf("expected only the third document to be rejected, got %d documents", len(rejected))
+	}
+	bulkError.WriteConcernError = &mongo.WriteConcernError{}
+	if !isTransientWriteError(bulkError) {
+		t.Errorf("write concern errors should be transient")
+	}
+	permanent := errors.New("invalid document")
+	if len(getRejectedDocuments(permanent, documents)) != len(documents) {
+		t.Errorf("expected all documents to be rejected for a permanent error")
+	}
+	directory := t.TempDir()
+	wal := &writeAheadLog{
+		directory: directory,
+		file: nil,
+		segment: 0,
+		size: 0,
+		opened: time.Time{},
+		collections: map[string]*mongo.Collection{},
+	}
+	setNotifiers([]notifier{})
+	wal.writeDeadLetters("book_events", rejected, permanent)
+	file, err := os.Open(filepath.Join(directory, walDeadLetterFile))
+	if err != nil {
+		t.Fatalf("Failed to open dead-letter file: %v", err)
+	}
+	defer file.Close()
+	records, _, err := readWALRecords(bufio.NewReader(file))
+	if err != io.EOF {
+		t.Fatalf("unexpected error reading dead-letter file: %v", err)
+	}
+	if len(records) != 1 || records[0].Collection != "book_events" || !equalDocuments(records[0].Document, documents[2]) {
+		t.Errorf("unexpected dead-letter records: %v", records)
+	}
+}
+
+func TestWALCheckpoint(t *testing.T) {
+	wal := &writeAheadLog{
+		directory: t.TempDir(),
+		file: nil,
+		segment: 0,
+		size: 0,
+		opened: time.Time{},
+		collections: map[string]*mongo.Collection{},
+	}
+	wal.saveCheckpoint(3, 100)
+	segment := wal.getLastSegment([]int{1, 2})
+	if segment != 3 {
+		t.Errorf("expected segment numbering to continue after the checkpoint, got %d", segment)
+	}
+	path := wal.getSegmentPath(3)
+	err := os.WriteFile(path, []byte{}, 0644)
+	if err != nil {
+		t.Fatalf("Failed to create segment: %v", err)
+	}
+	wal.drainSegment(3, newReconnectBackoff())
+	if commons.FileExists(path) {
+		t.Errorf("expected drained segment to have been removed")
+	}
+	if commons.FileExists(filepath.Join(wal.directory, walCheckpointFile)) {
+		t.Errorf("expected checkpoint of drained segment to have been removed")
+	}
+	segment = wal.getLastSegment([]int{})
+	if segment != 0 {
+		t.Errorf("expected segment numbering to restart without a checkpoint, got %d", segment)
+	}
+}
+
+func equalDocuments(first, second any) bool {
+	return string(first.(bson.Raw)) == string(second.(bson.Raw))
+}
\ No newline at end of file
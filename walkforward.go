@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/encratite/commons"
+	"github.com/olekukonko/tablewriter"
+	"github.com/olekukonko/tablewriter/tw"
+)
+
+const (
+	daysPerYear = 365.0
+)
+
+type WalkForwardDefinition struct {
+	TrainingDays *int `yaml:"trainingDays"`
+	TestDays *int `yaml:"testDays"`
+	Anchored bool `yaml:"anchored"`
+	Plot bool `yaml:"plot"`
+	EquityCurve *string `yaml:"equityCurve"`
+}
+
+type walkForwardFold struct {
+	trainingStart time.Time
+	trainingEnd time.Time
+	testStart time.Time
+	testEnd time.Time
+	best sweepResult
+	outOfSample backtestResult
+	outOfSampleValue float64
+}
+
+func runWalkForward(definition *BacktestDefinition) {
+	walkForward := definition.WalkForward
+	sweep := definition.Sweep
+	metric := sweep.getMetric()
+	combinations := sweep.getCombinations(definition)
+	folds := walkForward.getFolds(definition.Start.Time, definition.End.Time)
+	log.Printf("Running %d folds with %d parameter combinations each", len(folds), len(combinations))
+	data := definition.loadData()
+	fill := newFillModel(*definition.FillModel)
+	defer fill.close()
+	factory := strategyRegistry[*definition.Strategy]
+	for i := range folds {
+		fold := &folds[i]
+		results := executeSweep(definition, combinations, metric, fold.trainingStart, fold.trainingEnd, fill, data)
+		fold.best = getBestSweepResult(metric, results)
+		combination := fold.best.combination
+		strategy := factory(combination.tags, definition.ExcludeTags, combination.parameters)
+		fold.outOfSample = definition.execute(strategy, fold.testStart, fold.testEnd, fill, data)
+		fold.outOfSampleValue = metric.get(&fold.outOfSample)
+		log.Printf("Finished fold %d of %d", i + 1, len(folds))
+	}
+	printWalkForwardFolds(metric, folds)
+	equityCurve := getWalkForwardEquityCurve(*definition.InitialCash, folds)
+	last := equityCurve[len(equityCurve) - 1]
+	totalReturn := getRateOfChange(last.Cash, *definition.InitialCash)
+	fmt.Printf("\nOut-of-sample performance:\n")
+	fmt.Printf("\tStart: %s\n", commons.GetDateString(folds[0].testStart))
+	fmt.Printf("\tEnd: %s\n", commons.GetDateString(last.Timestamp))
+	fmt.Printf("\tCash: %s\n", commons.FormatMoney(last.Cash))
+	fmt.Printf("\tTotal return: %+.1f%%\n", percent * totalReturn)
+	fmt.Printf("\tMax drawdown: %.2f%%\n", percent * getMaxDrawdown(equityCurve))
+	fmt.Printf("\tSharpe ratio: %.2f\n", getSharpeRatio(equityCurve))
+	dailyEquityCurve := getDailyEquityCurve(equityCurve)
+	if walkForward.EquityCurve != nil {
+		writeJSON(*walkForward.EquityCurve, dailyEquityCurve)
+	}
+	if walkForward.Plot {
+		plotData("equity", dailyEquityCurve)
+	}
+}
+
+func (w *WalkForwardDefinition) validate(definition *BacktestDefinition) {
+	if w.TrainingDays == nil || *w.TrainingDays <= 0 {
+		log.Fatalf("Invalid training days in walk-forward definition")
+	}
+	if w.TestDays == nil || *w.TestDays <= 0 {
+		log.Fatalf("Invalid test days in walk-forward definition")
+	}
+	if definition.Sweep == nil {
+		log.Fatalf("Walk-forward optimization requires a sweep definition with the parameter ranges")
+	}
+	folds := w.getFolds(definition.Start.Time, definition.End.Time)
+	if len(folds) == 0 {
+		log.Fatalf("The backtest range is too short for a single walk-forward fold")
+	}
+}
+
+func (w *WalkForwardDefinition) getFolds(start, end time.Time) []walkForwardFold {
+	trainingDuration := time.Duration(*w.TrainingDays * hoursPerDay) * time.Hour
+	testDuration := time.Duration(*w.TestDays * hoursPerDay) * time.Hour
+	folds := []walkForwardFold{}
+	trainingStart := start
+	testStart := start.Add(trainingDuration)
+	for testStart.Before(end) {
+		testEnd := testStart.Add(testDuration)
+		if testEnd.After(end) {
+			testEnd = end
+		}
+		fold := walkForwardFold{
+			trainingStart: trainingStart,
+			trainingEnd: testStart,
+			testStart: testStart,
+			testEnd: testEnd,
+		}
+		folds = append(folds, fold)
+		if !w.Anchored {
+			trainingStart = trainingStart.Add(testDuration)
+		}
+		testStart = testEnd
+	}
+	return folds
+}
+
+func printWalkForwardFolds(metric backtestMetric, folds []walkForwardFold) {
+	header := []string{
+		"Fold",
+		"Training",
+		"Test",
+		"Parameters",
+		"In-Sample",
+		"Out-of-Sample",
+		"Degradation",
+		"IS Annualized",
+		"OOS Annualized",
+	}
+	rows := [][]string{}
+	for i, fold := range folds {
+		inSample := fold.best.value
+		outOfSample := fold.outOfSampleValue
+		degradation := inSample - outOfSample
+		if !metric.higherIsBetter {
+			degradation = - degradation
+		}
+		inSampleAnnualized := getAnnualizedReturn(&fold.best.result)
+		outOfSampleAnnualized := getAnnualizedReturn(&fold.outOfSample)
+		row := []string{
+			commons.IntToString(i + 1),
+			fmt.Sprintf("%s - %s", commons.GetDateString(fold.trainingStart), commons.GetDateString(fold.trainingEnd)),
+			fmt.Sprintf("%s - %s", commons.GetDateString(fold.testStart), commons.GetDateString(fold.testEnd)),
+			strings.Join(fold.best.combination.labels, ", "),
+			metric.format(inSample),
+			metric.format(outOfSample),
+			metric.format(degradation),
+			fmt.Sprintf("%+.1f%%", percent * inSampleAnnualized),
+			fmt.Sprintf("%+.1f%%", percent * outOfSampleAnnualized),
+		}
+		rows = append(rows, row)
+	}
+	alignments := []tw.Align{
+		tw.AlignRight,
+		tw.AlignDefault,
+		tw.AlignDefault,
+		tw.AlignDefault,
+		tw.AlignRight,
+		tw.AlignRight,
+		tw.AlignRight,
+		tw.AlignRight,
+		tw.AlignRight,
+	}
+	tableConfig := tablewriter.WithConfig(tablewriter.Config{
+		Header: tw.CellConfig{
+			Formatting: tw.CellFormatting{AutoFormat: tw.Off},
+			Alignment: tw.CellAlignment{Global: tw.AlignLeft},
+		}},
+	)
+	fmt.Printf("Walk-forward folds (%s):\n", strings.ToLower(metric.name))
+	alignmentConfig := tablewriter.WithAlignment(alignments)
+	table := tablewriter.NewTable(os.Stdout, tableConfig, alignmentConfig)
+	table.Header(header)
+	table.Bulk(rows)
+	table.Render()
+}
+
+func getWalkForwardEquityCurve(initialCash float64, folds []walkForwardFold) []EquityCurveSample {
+	equityCurve := []EquityCurveSample{
+		{
+			Timestamp: commons.GetDate(folds[0].testStart),
+			Cash: initialCash,
+		},
+	}
+	cash := initialCash
+	for _, fold := range folds {
+		factor := cash / initialCash
+		for _, sample := range fold.outOfSample.equityCurve[1:] {
+			scaledSample := EquityCurveSample{
+				Timestamp: sample.Timestamp,
+				Cash: factor * sample.Cash,
+			}
+			equityCurve = append(equityCurve, scaledSample)
+		}
+		cash = factor * fold.outOfSample.cash
+	}
+	return equityCurve
+}
+
+func getMaxDrawdown(equityCurve []EquityCurveSample) float64 {
+	maxCash := 0.0
+	maxDrawdown := 0.0
+	for _, sample := range equityCurve {
+		maxCash = max(maxCash, sample.Cash)
+		drawdown := 1.0 - sample.Cash / maxCash
+		maxDrawdown = max(maxDrawdown, drawdown)
+	}
+	return maxDrawdown
+}
+
+func getAnnualizedReturn(result *backtestResult) float64 {
+	days := result.end.Sub(result.start).Hours() / hoursPerDay
+	if days <= 0.0 {
+		return 0.0
+	}
+	return math.Pow(1.0 + result.totalReturn, daysPerYear / days) - 1.0
+}
\ No newline at end of file